@@ -0,0 +1,267 @@
+package revel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "log.access.format", Default: "combined", Description: "The format of AccessLogFilter: common, combined or json"},
+		ConfigOption{Name: "log.access.trustedproxies", Type: ConfigList, Description: "The IPs and CIDR ranges of the proxies whose X-Forwarded-For is trusted"},
+	)
+}
+
+// accessLog is the output of AccessLogFilter, or nil if off.
+var (
+	accessLog       io.Writer
+	accessLogFormat string
+	accessLogMutex  sync.Mutex
+)
+
+// configureAccessLog opens the output of the access log, given by
+// log.access.output ("off" by default), in the format of log.access.format.
+func configureAccessLog() *Error {
+	format := Config.StringDefault("log.access.format", "combined")
+	if format != "common" && format != "combined" && format != "json" {
+		return &Error{
+			Title:       "app.conf: Invalid log.access.format",
+			Description: "Expected common, combined or json, got: " + format,
+		}
+	}
+	output, err := logOutput("access", "off")
+	if err != nil {
+		return err
+	}
+
+	accessLogMutex.Lock()
+	defer accessLogMutex.Unlock()
+	accessLog, accessLogFormat = output, format
+	if output == io.Discard {
+		accessLog = nil
+	}
+	return nil
+}
+
+// AccessLogFilter writes a line per request to log.access.output, once the
+// response is written.  The line is in the Common or the Combined (the
+// default) Log Format, followed by the action, the latency in seconds and the
+// request ID:
+//
+//      10.0.0.1 - - [02/Jan/2006:15:04:05 -0700] "GET /hotels/3 HTTP/1.1" 200 2326 "-" "curl/8.0" Hotels.Show 0.002 4bf92f3577b34da6
+//
+// or, with "log.access.format = json", a JSON object with the same fields.
+// The client address is taken from X-Forwarded-For when the request comes
+// from one of log.access.trustedproxies (see Request.ClientIP).
+//
+// A request whose panic is not recovered, e.g. by the PanicFilter, is logged
+// with a 500 status unless another one was set.
+func AccessLogFilter(c *Controller, fc []Filter) {
+	accessLogMutex.Lock()
+	enabled := accessLog != nil
+	accessLogMutex.Unlock()
+	if !enabled {
+		fc[0](c, fc[1:])
+		return
+	}
+
+	start := time.Now()
+	out := &countingResponseWriter{ResponseWriter: c.Response.Out}
+	c.Response.Out = out
+	defer logAccessOnPanic(c, start, out)
+	fc[0](c, fc[1:])
+	if c.Result == nil {
+		logAccess(c, start, out.written)
+		return
+	}
+	c.Result = &accessLogResult{c.Result, c, start, out}
+}
+
+// accessLogResult applies a result, then logs the request.
+type accessLogResult struct {
+	Result
+	c     *Controller
+	start time.Time
+	out   *countingResponseWriter
+}
+
+func (r *accessLogResult) Apply(req *Request, resp *Response) {
+	defer logAccessOnPanic(r.c, r.start, r.out)
+	r.Result.Apply(req, resp)
+
+	// Flush the compressed response, to count every byte.
+	if closer, ok := resp.Out.(io.Closer); ok && resp.Out != http.ResponseWriter(r.out) {
+		closer.Close()
+		resp.Out = r.out
+	}
+	logAccess(r.c, r.start, r.out.written)
+}
+
+// logAccessOnPanic logs the request if it is panicking, then goes on
+// panicking.  It must be deferred.
+func logAccessOnPanic(c *Controller, start time.Time, out *countingResponseWriter) {
+	if err := recover(); err != nil {
+		if c.Response.Status == 0 {
+			c.Response.Status = http.StatusInternalServerError
+		}
+		logAccess(c, start, out.written)
+		panic(err)
+	}
+}
+
+// accessLogEntry is a line of the access log, in JSON.
+type accessLogEntry struct {
+	Time      time.Time `json:"time"`
+	ClientIP  string    `json:"client_ip"`
+	User      string    `json:"user,omitempty"`
+	Method    string    `json:"method"`
+	URI       string    `json:"uri"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Action    string    `json:"action,omitempty"`
+	LatencyMs float64   `json:"latency_ms"`
+	RequestID string    `json:"request_id"`
+}
+
+func logAccess(c *Controller, start time.Time, size int64) {
+	r := c.Request
+	entry := accessLogEntry{
+		Time:      start,
+		ClientIP:  r.ClientIP(),
+		Method:    r.Method,
+		URI:       r.RequestURI,
+		Proto:     r.Proto,
+		Status:    c.Response.Status,
+		Bytes:     size,
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+		Action:    c.Action,
+		LatencyMs: float64(time.Since(start)) / float64(time.Millisecond),
+		RequestID: r.ID,
+	}
+	if entry.Status == 0 {
+		entry.Status = http.StatusOK
+	}
+	if entry.URI == "" {
+		entry.URI = r.URL.RequestURI()
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		entry.User = user
+	}
+
+	accessLogMutex.Lock()
+	defer accessLogMutex.Unlock()
+	if accessLog == nil {
+		return
+	}
+	var line []byte
+	if accessLogFormat == "json" {
+		line, _ = json.Marshal(entry)
+	} else {
+		line = []byte(entry.format(accessLogFormat == "combined"))
+	}
+	accessLog.Write(append(line, '\n'))
+}
+
+// format returns the entry in the Common or Combined Log Format, followed by
+// the action, latency and request ID.
+func (e accessLogEntry) format(combined bool) string {
+	dash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	bytes := "-"
+	if e.Bytes > 0 {
+		bytes = strconv.FormatInt(e.Bytes, 10)
+	}
+	line := fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s`,
+		e.ClientIP, dash(e.User), e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, e.URI, e.Proto, e.Status, bytes)
+	if combined {
+		line += fmt.Sprintf(` %q %q`, dash(e.Referer), dash(e.UserAgent))
+	}
+	return line + fmt.Sprintf(" %s %.3f %s", dash(e.Action), e.LatencyMs/1000, e.RequestID)
+}
+
+// trustedProxies holds the parsed log.access.trustedproxies.
+var (
+	trustedProxies       []*net.IPNet
+	trustedProxiesOption string
+	trustedProxiesMutex  sync.Mutex
+)
+
+// isTrustedProxy returns true if the IP is one of log.access.trustedproxies,
+// a list of IPs and CIDR ranges, e.g. "10.0.0.0/8, 127.0.0.1".
+func isTrustedProxy(ip net.IP) bool {
+	if ip == nil || Config == nil {
+		return false
+	}
+	option := Config.StringDefault("log.access.trustedproxies", "")
+	trustedProxiesMutex.Lock()
+	defer trustedProxiesMutex.Unlock()
+	if option != trustedProxiesOption {
+		trustedProxies, trustedProxiesOption = nil, option
+		for _, proxy := range strings.Split(option, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy == "" {
+				continue
+			}
+			if !strings.Contains(proxy, "/") {
+				if strings.Contains(proxy, ":") {
+					proxy += "/128"
+				} else {
+					proxy += "/32"
+				}
+			}
+			_, network, err := net.ParseCIDR(proxy)
+			if err != nil {
+				WARN.Println("Invalid log.access.trustedproxies entry", proxy, ":", err)
+				continue
+			}
+			trustedProxies = append(trustedProxies, network)
+		}
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client: the remote address, or, if it
+// is one of log.access.trustedproxies, the last address of X-Forwarded-For
+// that is not a trusted proxy.
+func (req *Request) ClientIP() string {
+	ip := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if !isTrustedProxy(net.ParseIP(ip)) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !isTrustedProxy(net.ParseIP(hop)) {
+			break
+		}
+	}
+	return ip
+}
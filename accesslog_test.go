@@ -0,0 +1,165 @@
+package revel
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestAccessLogFilter(t *testing.T) {
+	startFakeBookingApp()
+	oldFilters := Filters
+	defer func() {
+		Filters = oldFilters
+		accessLog, accessLogFormat = nil, ""
+	}()
+	Filters = []Filter{AccessLogFilter, PanicFilter, RouterFilter, ParamsFilter, ActionInvoker}
+
+	var b bytes.Buffer
+	accessLog, accessLogFormat = &b, "combined"
+	req, _ := http.NewRequest("GET", "/hotels", nil)
+	req.RequestURI = "/hotels"
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("User-Agent", "curl/8.0")
+	req.Header.Set("X-Request-Id", "abc123")
+	handle(httptest.NewRecorder(), req)
+
+	pattern := regexp.MustCompile(`^10\.0\.0\.1 - - \[[^\]]+\] "GET /hotels HTTP/1\.1" 200 13 "-" "curl/8\.0" Hotels\.Index \d+\.\d{3} abc123\n$`)
+	if !pattern.MatchString(b.String()) {
+		t.Errorf("Unexpected combined log line: %q", b.String())
+	}
+
+	b.Reset()
+	accessLogFormat = "json"
+	req, _ = http.NewRequest("GET", "/nowhere", nil)
+	handle(httptest.NewRecorder(), req)
+	var entry accessLogEntry
+	if err := json.Unmarshal(b.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON line, got %q", b.String())
+	}
+	if entry.Status != http.StatusNotFound || entry.URI != "/nowhere" || entry.Action != "" || entry.Bytes == 0 || entry.RequestID == "" {
+		t.Errorf("Unexpected JSON log entry: %+v", entry)
+	}
+}
+
+type panicResult struct{}
+
+func (panicResult) Apply(req *Request, resp *Response) {
+	panic("failed to render")
+}
+
+func TestAccessLogFilterPanic(t *testing.T) {
+	startFakeBookingApp()
+	oldFilters := Filters
+	defer func() {
+		Filters = oldFilters
+		accessLog, accessLogFormat = nil, ""
+	}()
+
+	var b bytes.Buffer
+	accessLog, accessLogFormat = &b, "json"
+	for _, filter := range []Filter{
+		func(c *Controller, fc []Filter) { panic("failed to act") },
+		func(c *Controller, fc []Filter) { c.Result = panicResult{} },
+	} {
+		b.Reset()
+		Filters = []Filter{AccessLogFilter, filter}
+		req, _ := http.NewRequest("GET", "/hotels", nil)
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("Expected the panic to go on")
+				}
+			}()
+			handle(httptest.NewRecorder(), req)
+		}()
+
+		var entry accessLogEntry
+		if err := json.Unmarshal(b.Bytes(), &entry); err != nil {
+			t.Fatalf("Expected a JSON line, got %q", b.String())
+		}
+		if entry.Status != http.StatusInternalServerError || entry.URI != "/hotels" {
+			t.Errorf("Unexpected JSON log entry: %+v", entry)
+		}
+	}
+}
+
+// closeNotifyRecorder is a ResponseRecorder notified of the client closing.
+type closeNotifyRecorder struct {
+	*httptest.ResponseRecorder
+	closed chan bool
+}
+
+func (r closeNotifyRecorder) CloseNotify() <-chan bool {
+	return r.closed
+}
+
+func TestAccessLogFilterCompress(t *testing.T) {
+	startFakeBookingApp()
+	defer func() { accessLog, accessLogFormat = nil, "" }()
+	Config.SetOption("results.compressed", "true")
+	defer Config.SetOption("results.compressed", "false")
+
+	var b bytes.Buffer
+	accessLog, accessLogFormat = &b, "combined"
+	resp := closeNotifyRecorder{httptest.NewRecorder(), make(chan bool, 1)}
+	req, _ := http.NewRequest("GET", "/hotels", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	c := NewController(NewRequest(req), NewResponse(resp))
+	AccessLogFilter(c, []Filter{CompressFilter, func(c *Controller, fc []Filter) {
+		c.Result = c.RenderText("hello")
+	}})
+
+	writer, ok := c.Response.Out.(*CompressResponseWriter)
+	if !ok {
+		t.Fatalf("Expected a CompressResponseWriter, got %T", c.Response.Out)
+	}
+	resp.closed <- true
+	select {
+	case <-writer.CloseNotify():
+	default:
+		t.Error("Expected the CompressResponseWriter to be notified of the client closing")
+	}
+
+	counting := writer.ResponseWriter.(*countingResponseWriter)
+	counting.Flush()
+	if !resp.Flushed {
+		t.Error("Expected Flush to reach the ResponseWriter")
+	}
+	if counting.Unwrap() != http.ResponseWriter(resp) {
+		t.Error("Expected Unwrap to return the ResponseWriter")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+	Config = NewEmptyConfig()
+	Config.SetSection("prod")
+	Config.SetOption("log.access.trustedproxies", "10.0.0.0/8, ::1")
+
+	tests := []struct {
+		remoteAddr, forwardedFor, expected string
+	}{
+		{"203.0.113.7:1234", "", "203.0.113.7"},
+		{"203.0.113.7:1234", "198.51.100.1", "203.0.113.7"}, // Untrusted proxy
+		{"10.0.0.1:1234", "198.51.100.1", "198.51.100.1"},
+		{"[::1]:1234", "198.51.100.1, 10.1.2.3", "198.51.100.1"},
+		{"10.0.0.1:1234", "spoofed, 198.51.100.1, 10.1.2.3", "198.51.100.1"},
+		{"10.0.0.1:1234", "10.1.2.3", "10.1.2.3"},
+		{"10.0.0.1:1234", "", "10.0.0.1"},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+		if ip := NewRequest(req).ClientIP(); ip != test.expected {
+			t.Errorf("Expected %s from %s forwarded for %q, got %s", test.expected, test.remoteAddr, test.forwardedFor, ip)
+		}
+	}
+}
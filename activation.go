@@ -0,0 +1,183 @@
+package revel
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// The first file descriptor passed by socket activation (see sd_listen_fds(3)).
+const listenFdsStart = 3
+
+// inheritedListener is a listening socket passed to the process, either by
+// systemd socket activation or by the previous process of a handoff.
+type inheritedListener struct {
+	name     string // From LISTEN_FDNAMES, e.g. "secure"
+	listener net.Listener
+}
+
+// inheritedListeners returns the listening sockets passed with LISTEN_FDS and
+// LISTEN_FDNAMES, if they are meant for this process: LISTEN_PID is its pid
+// (systemd), or LISTEN_PARENT_PID is the pid of its parent (a handoff, in
+// which case that pid is returned too).  The variables are unset, so that they
+// are not passed on to child processes.
+func inheritedListeners() ([]*inheritedListener, int, error) {
+	var (
+		fds, _    = strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		pid, _    = strconv.Atoi(os.Getenv("LISTEN_PID"))
+		parent, _ = strconv.Atoi(os.Getenv("LISTEN_PARENT_PID"))
+		names     = strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	)
+	for _, name := range []string{"LISTEN_FDS", "LISTEN_PID", "LISTEN_FDNAMES", "LISTEN_PARENT_PID"} {
+		os.Unsetenv(name)
+	}
+	if parent != os.Getppid() {
+		parent = 0
+	}
+	if fds <= 0 || (pid != os.Getpid() && parent == 0) {
+		return nil, 0, nil
+	}
+
+	var inherited []*inheritedListener
+	for i := 0; i < fds; i++ {
+		var name string
+		if i < len(names) {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(listenFdsStart+i), name)
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, opened := range inherited {
+				opened.listener.Close()
+			}
+			return nil, 0, fmt.Errorf("inherited file descriptor %d (%s) is not a listening socket: %s",
+				listenFdsStart+i, name, err)
+		}
+		inherited = append(inherited, &inheritedListener{name, listener})
+	}
+	return inherited, parent, nil
+}
+
+// takeInheritedListener removes and returns the inherited socket meant for the
+// given listener: the one named after it, or else the one bound to its
+// address.  A single socket is given to a single unnamed listener regardless,
+// as systemd names the sockets after their unit by default.
+func takeInheritedListener(inherited []*inheritedListener, l *serverListener, single bool) ([]*inheritedListener, net.Listener) {
+	match := -1
+	for i, candidate := range inherited {
+		if l.Name != "" && candidate.name == l.Name {
+			match = i
+			break
+		}
+		if match == -1 && sameAddress(l, candidate.listener.Addr()) {
+			match = i
+		}
+	}
+	if match == -1 && single && l.Name == "" && len(inherited) == 1 {
+		match = 0
+	}
+	if match == -1 {
+		return inherited, nil
+	}
+	listener := inherited[match].listener
+	return append(inherited[:match:match], inherited[match+1:]...), listener
+}
+
+// sameAddress returns true if the listener is configured for the given address.
+func sameAddress(l *serverListener, addr net.Addr) bool {
+	switch addr := addr.(type) {
+	case *net.UnixAddr:
+		return l.Network == "unix" && l.Address == addr.Name
+	case *net.TCPAddr:
+		if !strings.HasPrefix(l.Network, "tcp") {
+			return false
+		}
+		configured, err := net.ResolveTCPAddr(l.Network, l.Address)
+		if err != nil || configured.Port != addr.Port {
+			return false
+		}
+		return configured.IP == nil || configured.IP.IsUnspecified() || configured.IP.Equal(addr.IP)
+	}
+	return false
+}
+
+// handOff starts a new process of the program, with the same arguments and
+// environment, passing it the listening sockets.  Once the new process serves
+// requests, it sends SIGTERM to this one, which then drains and stops as usual.
+// This allows restarting with a new binary without refusing any connection.
+func handOff(listeners []*serverListener, netListeners []net.Listener) error {
+	var (
+		files []*os.File
+		names []string
+	)
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for i, netListener := range netListeners {
+		fileListener, ok := netListener.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			return fmt.Errorf("can not pass a %T to a new process", netListener)
+		}
+		// Leave the socket file to the new process.
+		if unixListener, ok := netListener.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+		file, err := fileListener.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		names = append(names, listeners[i].Name)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		"LISTEN_FDS="+strconv.Itoa(len(files)),
+		"LISTEN_FDNAMES="+strings.Join(names, ":"),
+		"LISTEN_PARENT_PID="+strconv.Itoa(os.Getpid()))
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	INFO.Println("Handed the listeners off to process", cmd.Process.Pid)
+	return nil
+}
+
+// notifyReady tells the supervisor that the process is serving requests:
+// systemd, if it set NOTIFY_SOCKET (with Type=notify, so that it follows the
+// main process across handoffs), and the previous process of a handoff, which
+// is asked to stop.
+func notifyReady(parent int) {
+	if socket := os.Getenv("NOTIFY_SOCKET"); socket != "" {
+		conn, err := net.Dial("unixgram", socket)
+		if err == nil {
+			_, err = fmt.Fprintf(conn, "MAINPID=%d\nREADY=1", os.Getpid())
+			conn.Close()
+		}
+		if err != nil {
+			WARN.Println("Failed to notify systemd:", err)
+		}
+	}
+
+	if parent != 0 {
+		if process, err := os.FindProcess(parent); err == nil {
+			if err = process.Signal(syscall.SIGTERM); err != nil {
+				WARN.Println("Failed to stop the previous process", parent, ":", err)
+			}
+		}
+	}
+}
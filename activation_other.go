@@ -0,0 +1,8 @@
+//go:build !unix
+
+package revel
+
+import "os"
+
+// handoffSignal is nil where there is no SIGUSR2: handoffs are not supported.
+var handoffSignal os.Signal
@@ -0,0 +1,130 @@
+package revel
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestTakeInheritedListener(t *testing.T) {
+	var inherited []*inheritedListener
+	for _, name := range []string{"secure", "web"} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		inherited = append(inherited, &inheritedListener{name, listener})
+	}
+	webAddr := inherited[1].listener.Addr().String()
+	_, webPort, _ := net.SplitHostPort(webAddr)
+
+	// By name.
+	rest, listener := takeInheritedListener(inherited, &serverListener{Name: "web", Network: "tcp", Address: ":80"}, false)
+	if listener != inherited[1].listener || len(rest) != 1 || rest[0] != inherited[0] {
+		t.Errorf("Expected the listener named web, got %v (left %d)", listener, len(rest))
+	}
+
+	// By address, including the unspecified host.
+	for _, address := range []string{webAddr, ":" + webPort} {
+		rest, listener = takeInheritedListener(inherited, &serverListener{Network: "tcp", Address: address}, false)
+		if listener != inherited[1].listener || len(rest) != 1 {
+			t.Errorf("Expected the listener on %s, got %v", address, listener)
+		}
+	}
+
+	// No match.
+	rest, listener = takeInheritedListener(inherited, &serverListener{Name: "other", Network: "tcp", Address: "127.0.0.1:1"}, false)
+	if listener != nil || len(rest) != 2 {
+		t.Errorf("Expected no listener, got %v", listener)
+	}
+
+	// A single socket for a single unnamed listener.
+	rest, listener = takeInheritedListener(inherited[:1], &serverListener{Network: "tcp", Address: ":80"}, true)
+	if listener != inherited[0].listener || len(rest) != 0 {
+		t.Errorf("Expected the single inherited listener, got %v", listener)
+	}
+}
+
+// TestInheritedListeners passes a listening socket to a new process of the
+// test binary, like a handoff does, and checks that it serves on it.
+func TestInheritedListeners(t *testing.T) {
+	if os.Getenv("REVEL_TEST_INHERIT") == "1" {
+		inheritedListenersHelper()
+		return
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("no file descriptor inheritance on windows")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	file, err := listener.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestInheritedListeners$")
+	cmd.ExtraFiles = []*os.File{file}
+	cmd.Env = append(os.Environ(),
+		"REVEL_TEST_INHERIT=1",
+		"LISTEN_FDS=1",
+		"LISTEN_FDNAMES=web",
+		"LISTEN_PARENT_PID="+strconv.Itoa(os.Getpid()))
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+
+	// Close our copy, so that only the new process accepts.
+	listener.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("web %d", os.Getpid())
+	if string(reply) != expected {
+		out, _ := io.ReadAll(output)
+		t.Errorf("Expected %q from the new process, got %q (output: %s)", expected, reply, out)
+	}
+}
+
+// inheritedListenersHelper runs in the new process of TestInheritedListeners,
+// replying with the name of the inherited listener and the parent pid.
+func inheritedListenersHelper() {
+	inherited, parent, err := inheritedListeners()
+	if err != nil || len(inherited) != 1 {
+		fmt.Println("Expected one inherited listener, got", len(inherited), err)
+		os.Exit(1)
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		fmt.Println("Expected LISTEN_FDS to be unset")
+		os.Exit(1)
+	}
+	conn, err := inherited[0].listener.Accept()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(conn, "%s %d", inherited[0].name, parent)
+	conn.Close()
+	os.Exit(0)
+}
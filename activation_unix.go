@@ -0,0 +1,11 @@
+//go:build unix
+
+package revel
+
+import (
+	"os"
+	"syscall"
+)
+
+// handoffSignal asks the server to hand its listeners off to a new process.
+var handoffSignal os.Signal = syscall.SIGUSR2
@@ -193,6 +193,11 @@ var (
 // Sadly, the binder lookups can not be declared initialized -- that results in
 // an "initialization loop" compile error.
 func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "format.date", Default: "2006-01-02", Description: "The layout used to bind dates"},
+		ConfigOption{Name: "format.datetime", Default: "2006-01-02 15:04", Description: "The layout used to bind date-times"},
+	)
+
 	KindBinders[reflect.Int] = IntBinder
 	KindBinders[reflect.Int8] = IntBinder
 	KindBinders[reflect.Int16] = IntBinder
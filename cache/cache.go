@@ -3,6 +3,8 @@ package cache
 import (
 	"errors"
 	"time"
+
+	"github.com/revel/revel"
 )
 
 // Length of time to cache an item.
@@ -114,6 +116,13 @@ type Cache interface {
 	Flush() error
 }
 
+// Pinger is implemented by the caches that can check the connection to their
+// backend, as the memcached and Redis caches do.  The cache module registers a
+// readiness check pinging the Instance (see revel.RegisterReadinessCheck).
+type Pinger interface {
+	Ping() error
+}
+
 var (
 	Instance Cache
 
@@ -123,7 +132,6 @@ var (
 
 // The package implements the Cache interface (as sugar).
 
-func Get(key string, ptrValue interface{}) error                  { return Instance.Get(key, ptrValue) }
 func GetMulti(keys ...string) (Getter, error)                     { return Instance.GetMulti(keys...) }
 func Delete(key string) error                                     { return Instance.Delete(key) }
 func Increment(key string, n uint64) (newValue uint64, err error) { return Instance.Increment(key, n) }
@@ -138,3 +146,19 @@ func Add(key string, value interface{}, expires time.Duration) error {
 func Replace(key string, value interface{}, expires time.Duration) error {
 	return Instance.Replace(key, value, expires)
 }
+
+var (
+	cacheHits   = revel.NewCounter("revel_cache_hits_total", "The number of values found by cache.Get.")
+	cacheMisses = revel.NewCounter("revel_cache_misses_total", "The number of values not found by cache.Get.")
+)
+
+// Get counts the hits and misses (see revel.MetricsFilter).
+func Get(key string, ptrValue interface{}) error {
+	err := Instance.Get(key, ptrValue)
+	if err == nil {
+		cacheHits.Inc()
+	} else if err == ErrCacheMiss {
+		cacheMisses.Inc()
+	}
+	return err
+}
@@ -240,3 +240,30 @@ func testGetMulti(t *testing.T, newCache cacheFactory) {
 		t.Errorf("Error getting foo: %s / %v", err, foo)
 	}
 }
+
+// closingCache records that it was closed.
+type closingCache struct {
+	Cache
+	closed *bool
+}
+
+func (c closingCache) Close() error {
+	*c.closed = true
+	return nil
+}
+
+func TestSetInstanceClosesPrevious(t *testing.T) {
+	oldInstance := Instance
+	defer func() { Instance = oldInstance }()
+
+	closed := false
+	Instance = closingCache{NewInMemoryCache(time.Hour), &closed}
+	next := NewInMemoryCache(time.Hour)
+	setInstance(next)
+	if !closed {
+		t.Error("Expected the previous cache to be closed")
+	}
+	if Instance != Cache(next) {
+		t.Error("Expected the new cache to be the Instance")
+	}
+}
@@ -2,52 +2,124 @@ package cache
 
 import (
 	"github.com/revel/revel"
+	"io"
+	"sort"
 	"strings"
 	"time"
 )
 
 func init() {
-	revel.OnAppStart(func() {
-		// Set the default expiration time.
-		defaultExpiration := time.Hour // The default for the default is one hour.
-		if expireStr, found := revel.Config.String("cache.expires"); found {
-			var err error
-			if defaultExpiration, err = time.ParseDuration(expireStr); err != nil {
-				panic("Could not parse default cache expiration duration " + expireStr + ": " + err.Error())
-			}
+	revel.RegisterConfigOptions(
+		revel.ConfigOption{Name: "cache.expires", Type: revel.ConfigDuration, Default: "1h",
+			Description: "The default expiration of cached values"},
+		revel.ConfigOption{Name: "cache.memcached", Type: revel.ConfigBool, Default: "false",
+			Description: "Whether to use memcached"},
+		revel.ConfigOption{Name: "cache.redis", Type: revel.ConfigBool, Default: "false",
+			Description: "Whether to use Redis"},
+		revel.ConfigOption{Name: "cache.hosts", Type: revel.ConfigList,
+			Description: "The memcached hosts, or the Redis host"},
+		revel.ConfigOption{Name: "cache.redis.password", Description: "The Redis password"},
+		revel.ConfigOption{Name: "cache.redis.protocol", Default: "tcp", Description: "The network of the Redis host"},
+		revel.ConfigOption{Name: "cache.redis.maxidle", Type: revel.ConfigInt, Default: "5",
+			Description: "The maximum number of idle Redis connections"},
+		revel.ConfigOption{Name: "cache.redis.maxactive", Type: revel.ConfigInt, Default: "0",
+			Description: "The maximum number of Redis connections, or 0 for no limit"},
+		revel.ConfigOption{Name: "cache.redis.idletimeout", Type: revel.ConfigInt, Default: "240",
+			Description: "The seconds after which idle Redis connections are closed"},
+		revel.ConfigOption{Name: "cache.redis.timeout.connect", Type: revel.ConfigInt, Default: "10000",
+			Description: "The Redis connect timeout, in milliseconds"},
+		revel.ConfigOption{Name: "cache.redis.timeout.read", Type: revel.ConfigInt, Default: "5000",
+			Description: "The Redis read timeout, in milliseconds"},
+		revel.ConfigOption{Name: "cache.redis.timeout.write", Type: revel.ConfigInt, Default: "5000",
+			Description: "The Redis write timeout, in milliseconds"},
+	)
+
+	revel.OnAppStart(initCache)
+
+	// Report the app not ready while the cache backend is unreachable.
+	revel.RegisterReadinessCheck("cache", func() error {
+		if pinger, ok := Instance.(Pinger); ok {
+			return pinger.Ping()
 		}
+		return nil
+	})
 
-		// make sure you aren't trying to use both memcached and redis
-		if revel.Config.BoolDefault("cache.memcached", false) && revel.Config.BoolDefault("cache.redis", false) {
-			panic("You've configured both memcached and redis, please only include configuration for one cache!")
+	// Switch to the new cache when its settings are changed in app.conf.
+	revel.OnConfigChange(func() {
+		if settings := cacheSettings(); settings != currentSettings {
+			revel.INFO.Println("Cache settings changed, reinitializing the cache")
+			initCache()
 		}
+	})
+}
 
-		// Use memcached?
-		if revel.Config.BoolDefault("cache.memcached", false) {
-			hosts := strings.Split(revel.Config.StringDefault("cache.hosts", ""), ",")
-			if len(hosts) == 0 {
-				panic("Memcache enabled but no memcached hosts specified!")
-			}
+// currentSettings holds the cache.* options used to create the Instance.
+var currentSettings string
 
-			Instance = NewMemcachedCache(hosts, defaultExpiration)
-			return
+// cacheSettings returns the cache.* options, to detect changes to them.
+func cacheSettings() string {
+	var settings []string
+	for _, option := range revel.Config.Options("cache.") {
+		settings = append(settings, option+"="+revel.Config.StringDefault(option, ""))
+	}
+	sort.Strings(settings)
+	return strings.Join(settings, "\n")
+}
+
+func initCache() {
+	currentSettings = cacheSettings()
+
+	// Set the default expiration time.
+	defaultExpiration := time.Hour // The default for the default is one hour.
+	if expireStr, found := revel.Config.String("cache.expires"); found {
+		var err error
+		if defaultExpiration, err = time.ParseDuration(expireStr); err != nil {
+			panic("Could not parse default cache expiration duration " + expireStr + ": " + err.Error())
 		}
+	}
+
+	// make sure you aren't trying to use both memcached and redis
+	if revel.Config.BoolDefault("cache.memcached", false) && revel.Config.BoolDefault("cache.redis", false) {
+		panic("You've configured both memcached and redis, please only include configuration for one cache!")
+	}
 
-		// Use Redis (share same config as memcached)?
-		if revel.Config.BoolDefault("cache.redis", false) {
-			hosts := strings.Split(revel.Config.StringDefault("cache.hosts", ""), ",")
-			if len(hosts) == 0 {
-				panic("Redis enabled but no Redis hosts specified!")
-			}
-			if len(hosts) > 1 {
-				panic("Redis currently only supports one host!")
-			}
-			password := revel.Config.StringDefault("cache.redis.password", "")
-			Instance = NewRedisCache(hosts[0], password, defaultExpiration)
-			return
+	// Use memcached?
+	if revel.Config.BoolDefault("cache.memcached", false) {
+		hosts := strings.Split(revel.Config.StringDefault("cache.hosts", ""), ",")
+		if len(hosts) == 0 {
+			panic("Memcache enabled but no memcached hosts specified!")
 		}
 
-		// By default, use the in-memory cache.
-		Instance = NewInMemoryCache(defaultExpiration)
-	})
+		setInstance(NewMemcachedCache(hosts, defaultExpiration))
+		return
+	}
+
+	// Use Redis (share same config as memcached)?
+	if revel.Config.BoolDefault("cache.redis", false) {
+		hosts := strings.Split(revel.Config.StringDefault("cache.hosts", ""), ",")
+		if len(hosts) == 0 {
+			panic("Redis enabled but no Redis hosts specified!")
+		}
+		if len(hosts) > 1 {
+			panic("Redis currently only supports one host!")
+		}
+		password := revel.Config.StringDefault("cache.redis.password", "")
+		setInstance(NewRedisCache(hosts[0], password, defaultExpiration))
+		return
+	}
+
+	// By default, use the in-memory cache.
+	setInstance(NewInMemoryCache(defaultExpiration))
+}
+
+// setInstance replaces the Instance, then closes the previous one, if it
+// holds connections to close, e.g. once the cache settings changed.
+func setInstance(cache Cache) {
+	previous := Instance
+	Instance = cache
+	if closer, ok := previous.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			revel.WARN.Println("Failed to close the previous cache:", err)
+		}
+	}
 }
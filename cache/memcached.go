@@ -65,6 +65,11 @@ func (c MemcachedCache) Flush() error {
 	return err
 }
 
+// Ping checks the connection to every memcached server.
+func (c MemcachedCache) Ping() error {
+	return c.Client.Ping()
+}
+
 func (c MemcachedCache) invoke(f func(*memcache.Client, *memcache.Item) error,
 	key string, value interface{}, expires time.Duration) error {
 
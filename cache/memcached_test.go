@@ -48,3 +48,29 @@ func TestMemcachedCache_Add(t *testing.T) {
 func TestMemcachedCache_GetMulti(t *testing.T) {
 	testGetMulti(t, newMemcachedCache)
 }
+
+func TestMemcachedCache_Ping(t *testing.T) {
+	c := newMemcachedCache(t, time.Hour)
+	if err := c.(Pinger).Ping(); err != nil {
+		t.Errorf("Expected memcached to answer, got %s", err)
+	}
+}
+
+func TestPingUnreachableMemcached(t *testing.T) {
+	// Find a port with nothing listening on it.
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	var c Cache = NewMemcachedCache([]string{addr}, time.Hour)
+	pinger, ok := c.(Pinger)
+	if !ok {
+		t.Fatal("Expected the memcached cache to be a Pinger")
+	}
+	if err := pinger.Ping(); err == nil {
+		t.Error("Expected an error pinging an unreachable memcached")
+	}
+}
@@ -242,3 +242,16 @@ func (g RedisItemMapGetter) Get(key string, ptrValue interface{}) error {
 	}
 	return Deserialize(item, ptrValue)
 }
+
+// Ping checks the connection to the Redis server.
+func (c RedisCache) Ping() error {
+	conn := c.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("PING")
+	return err
+}
+
+// Close closes the connections to the Redis server.
+func (c RedisCache) Close() error {
+	return c.pool.Close()
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/revel/revel"
+)
+
+// Traced returns the Instance, running each operation in a span of the
+// current trace of the context (see revel.TracingFilter).  For example:
+//
+//      var bookings []*models.Booking
+//      err := cache.Traced(c.Request.Context()).Get("bookings:"+user, &bookings)
+//
+// A miss is not reported as an error of the span.
+func Traced(ctx context.Context) Cache {
+	if revel.SpanFromContext(ctx) == nil {
+		return Instance
+	}
+	return tracedCache{Instance, ctx}
+}
+
+type tracedCache struct {
+	Cache
+	ctx context.Context
+}
+
+// trace runs an operation in a span named e.g. "cache.Get".
+func (c tracedCache) trace(operation string, keys []string, f func() error) error {
+	_, span := revel.StartSpan(c.ctx, "cache."+operation)
+	if len(keys) > 0 {
+		span.SetAttribute("cache.key", strings.Join(keys, " "))
+	}
+	err := f()
+	if err != nil && err != ErrCacheMiss && err != ErrNotStored {
+		span.SetError(err)
+	} else if operation == "Get" {
+		span.SetAttribute("cache.hit", err == nil)
+	}
+	span.End()
+	return err
+}
+
+func (c tracedCache) Get(key string, ptrValue interface{}) error {
+	return c.trace("Get", []string{key}, func() error { return c.Cache.Get(key, ptrValue) })
+}
+
+func (c tracedCache) GetMulti(keys ...string) (getter Getter, err error) {
+	err = c.trace("GetMulti", keys, func() error {
+		getter, err = c.Cache.GetMulti(keys...)
+		return err
+	})
+	return
+}
+
+func (c tracedCache) Set(key string, value interface{}, expires time.Duration) error {
+	return c.trace("Set", []string{key}, func() error { return c.Cache.Set(key, value, expires) })
+}
+
+func (c tracedCache) Add(key string, value interface{}, expires time.Duration) error {
+	return c.trace("Add", []string{key}, func() error { return c.Cache.Add(key, value, expires) })
+}
+
+func (c tracedCache) Replace(key string, value interface{}, expires time.Duration) error {
+	return c.trace("Replace", []string{key}, func() error { return c.Cache.Replace(key, value, expires) })
+}
+
+func (c tracedCache) Delete(key string) error {
+	return c.trace("Delete", []string{key}, func() error { return c.Cache.Delete(key) })
+}
+
+func (c tracedCache) Increment(key string, n uint64) (newValue uint64, err error) {
+	err = c.trace("Increment", []string{key}, func() error {
+		newValue, err = c.Cache.Increment(key, n)
+		return err
+	})
+	return
+}
+
+func (c tracedCache) Decrement(key string, n uint64) (newValue uint64, err error) {
+	err = c.trace("Decrement", []string{key}, func() error {
+		newValue, err = c.Cache.Decrement(key, n)
+		return err
+	})
+	return
+}
+
+func (c tracedCache) Flush() error {
+	return c.trace("Flush", nil, c.Cache.Flush)
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/revel/revel"
+)
+
+func TestTraced(t *testing.T) {
+	oldInstance, oldExporter := Instance, revel.TraceExporter
+	defer func() { Instance, revel.TraceExporter = oldInstance, oldExporter }()
+	exporter := &revel.InMemoryExporter{}
+	revel.TraceExporter = exporter
+	Instance = NewInMemoryCache(time.Hour)
+
+	if Traced(context.Background()) != Instance {
+		t.Error("Expected the Instance itself outside of a trace")
+	}
+
+	ctx, root := revel.StartTrace(context.Background(), "job")
+	cache := Traced(ctx)
+	cache.Set("int", 1, DEFAULT)
+	var value int
+	cache.Get("int", &value)
+	cache.Get("missing", &value)
+	root.End()
+
+	spans := exporter.Spans()
+	expected := []struct {
+		name string
+		hit  interface{}
+	}{{"cache.Set", nil}, {"cache.Get", true}, {"cache.Get", false}, {"job", nil}}
+	if len(spans) != len(expected) {
+		t.Fatalf("Expected %d spans, got %d", len(expected), len(spans))
+	}
+	for i, span := range spans {
+		if span.Name != expected[i].name || span.Attributes["cache.hit"] != expected[i].hit {
+			t.Errorf("Expected span %s (hit: %v), got %s %v", expected[i].name, expected[i].hit, span.Name, span.Attributes)
+		}
+		if span.TraceID != root.TraceID || span.Error != "" {
+			t.Errorf("Expected span %s in the trace without error, got %+v", span.Name, span)
+		}
+		if i < 3 && span.ParentID != root.SpanID {
+			t.Errorf("Expected span %s to be a child of the job", span.Name)
+		}
+	}
+}
@@ -9,6 +9,12 @@ import (
 	"strings"
 )
 
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "results.compressed", Type: ConfigBool, Default: "false", Description: "Whether to compress responses"},
+	)
+}
+
 var compressionTypes = [...]string{
 	"gzip",
 	"deflate",
@@ -2,60 +2,191 @@ package revel
 
 import (
 	"errors"
+	"fmt"
 	"github.com/robfig/config"
+	"os"
 	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 )
 
 // This handles the parsing of app.conf
 // It has a "preferred" section that is checked first for option queries.
 // If the preferred section does not have the option, the DEFAULT section is
 // checked fallback.
+//
+// Environment variables
+//
+// If an environment prefix is set (Init sets "REVEL_" for app.conf), an
+// environment variable named after an option overrides that option in every
+// section.  The name is the prefix followed by the option name in upper case,
+// with dots and dashes replaced by underscores:
+//
+//     http.port             => REVEL_HTTP_PORT
+//     db.url                => REVEL_DB_URL
+//     i18n.default_language => REVEL_I18N_DEFAULT_LANGUAGE
+//
+// Option values may also refer to environment variables, which are
+// interpolated on every lookup:
+//
+//     db.url = ${DATABASE_URL}                  # not found if DATABASE_URL is unset
+//     db.url = ${DATABASE_URL:-sqlite3://test}  # with a default value
+//
+// Values may refer to other options with %(option)s, as before.
+//
+// Reloading
+//
+// A MergedConfig loaded from a file is a Listener: in dev mode, the watcher
+// calls Refresh when app.conf changes, which replaces the options atomically
+// and runs the OnConfigChange hooks.  The options read through the
+// MergedConfig change, e.g. results.compressed, but not the package variables
+// set by Init, e.g. CookieDomain.
 type MergedConfig struct {
-	config  *config.Config
-	section string // Check this section first, then fall back to DEFAULT
+	config    *config.Config
+	section   string // Check this section first, then fall back to DEFAULT
+	envPrefix string // e.g. "REVEL_", or "" to ignore the environment
+	confName  string // e.g. "app.conf", the file to reload on Refresh
+	content   string // The content last loaded, to detect changes
+	mutex     sync.RWMutex
 }
 
 func NewEmptyConfig() *MergedConfig {
-	return &MergedConfig{config.NewDefault(), ""}
+	return &MergedConfig{config: config.NewDefault()}
 }
 
 func LoadConfig(confName string) (*MergedConfig, error) {
-	var err error
+	conf, content, err := loadConfigFile(confName)
+	if err != nil {
+		return nil, err
+	}
+	return &MergedConfig{config: conf, confName: confName, content: content}, nil
+}
+
+// loadConfigFile reads the first file with the given name within ConfPaths,
+// returning the parsed config and its content.  For app.conf, the files in the
+// conf.d directory next to it are merged in too.
+func loadConfigFile(confName string) (*config.Config, string, error) {
 	for _, confPath := range ConfPaths {
-		conf, err := config.ReadDefault(path.Join(confPath, confName))
-		if err == nil {
-			return &MergedConfig{conf, ""}, nil
+		filename := path.Join(confPath, confName)
+		if !sourceFileExists(filename) {
+			continue
 		}
+
+		loader := &configLoader{conf: config.NewDefault()}
+		if err := loader.readFile(filename, ""); err != nil {
+			return nil, "", err
+		}
+		if confName == "app.conf" {
+			overlay := path.Join(confPath, configOverlayDir, "*.conf")
+			if err := loader.include(overlay, "", true); err != nil {
+				if revelError, ok := err.(*Error); ok {
+					return nil, "", revelError
+				}
+				return nil, "", &Error{
+					Title:       "Failed to read config file",
+					Path:        path.Join(confPath, configOverlayDir),
+					Description: err.Error(),
+				}
+			}
+		}
+		return loader.conf, loader.content.String(), nil
 	}
-	if err == nil {
-		err = errors.New("not found")
-	}
-	return nil, err
+	return nil, "", errors.New("not found in " + strings.Join(ConfPaths, ", "))
 }
 
 func (c *MergedConfig) Raw() *config.Config {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return c.config
 }
 
+// Refresh reloads the config file, replacing the options if it has changed.
+// If c is the app's Config, it is checked and the OnConfigChange hooks are
+// run.  The package variables set by Init from the options are not updated.
+func (c *MergedConfig) Refresh() *Error {
+	if c.confName == "" {
+		return nil
+	}
+
+	conf, content, err := loadConfigFile(c.confName)
+	if err != nil {
+		if revelError, ok := err.(*Error); ok {
+			return revelError
+		}
+		return &Error{
+			Title:       "Failed to reload " + c.confName,
+			Description: err.Error(),
+		}
+	}
+	if content == c.content {
+		return nil
+	}
+	if c.section != "" && !conf.HasSection(c.section) {
+		return &Error{
+			Title:       c.confName + ": No mode found",
+			Description: "The run mode " + c.section + " does not appear in " + c.confName,
+		}
+	}
+
+	c.mutex.Lock()
+	c.config, c.content = conf, content
+	c.mutex.Unlock()
+	INFO.Println("Reloaded", c.confName)
+
+	if c != Config {
+		return nil
+	}
+	if err := checkConfig(); err != nil {
+		return err
+	}
+	return runConfigChangeHooks()
+}
+
+// WatchDir returns true for the conf directory and its subdirectories (such as
+// conf.d), except the ones starting with a dot.
+func (c *MergedConfig) WatchDir(info os.FileInfo) bool {
+	return !strings.HasPrefix(info.Name(), ".")
+}
+
+// WatchFile returns true for the config file and the files it may include.
+// Changes to other files are ignored by Refresh, which compares the content.
+func (c *MergedConfig) WatchFile(basename string) bool {
+	basename = filepath.Base(basename)
+	return basename == c.confName || strings.HasSuffix(basename, ".conf")
+}
+
 func (c *MergedConfig) SetSection(section string) {
 	c.section = section
 }
 
+// SetEnvPrefix enables the environment variable overrides, for variables
+// named with the given prefix.  An empty prefix disables them.
+func (c *MergedConfig) SetEnvPrefix(prefix string) {
+	c.envPrefix = prefix
+}
+
 func (c *MergedConfig) SetOption(name, value string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.config.AddOption(c.section, name, value)
 }
 
 func (c *MergedConfig) Int(option string) (result int, found bool) {
-	result, err := c.config.Int(c.section, option)
+	value, found := c.String(option)
+	if !found {
+		return 0, false
+	}
+	result, err := strconv.Atoi(value)
 	if err == nil {
 		return result, true
 	}
-	if _, ok := err.(config.OptionError); ok {
-		return 0, false
-	}
 
-	// If it wasn't an OptionError, it must have failed to parse.
 	ERROR.Println("Failed to parse config option", option, "as int:", err)
 	return 0, false
 }
@@ -68,16 +199,16 @@ func (c *MergedConfig) IntDefault(option string, dfault int) int {
 }
 
 func (c *MergedConfig) Bool(option string) (result, found bool) {
-	result, err := c.config.Bool(c.section, option)
-	if err == nil {
-		return result, true
-	}
-	if _, ok := err.(config.OptionError); ok {
+	value, found := c.String(option)
+	if !found {
 		return false, false
 	}
+	result, ok := configBoolStrings[strings.ToLower(value)]
+	if ok {
+		return result, true
+	}
 
-	// If it wasn't an OptionError, it must have failed to parse.
-	ERROR.Println("Failed to parse config option", option, "as bool:", err)
+	ERROR.Println("Failed to parse config option", option, "as bool:", value)
 	return false, false
 }
 
@@ -89,10 +220,7 @@ func (c *MergedConfig) BoolDefault(option string, dfault bool) bool {
 }
 
 func (c *MergedConfig) String(option string) (result string, found bool) {
-	if r, err := c.config.String(c.section, option); err == nil {
-		return stripQuotes(r), true
-	}
-	return "", false
+	return c.lookup(option, 0)
 }
 
 func (c *MergedConfig) StringDefault(option, dfault string) string {
@@ -102,25 +230,479 @@ func (c *MergedConfig) StringDefault(option, dfault string) string {
 	return dfault
 }
 
+// lookup returns the interpolated value of the option, taking the environment
+// overrides into account.  depth guards against cycles between options.
+func (c *MergedConfig) lookup(option string, depth int) (string, bool) {
+	if depth > maxConfigInterpolationDepth {
+		ERROR.Println("Failed to interpolate config option", option, ": possible cycle")
+		return "", false
+	}
+
+	value, found := c.envOverride(option)
+	if !found {
+		var err error
+		if value, err = c.Raw().RawString(c.section, option); err != nil {
+			return "", false
+		}
+	}
+
+	// Environment variables: ${NAME} and ${NAME:-default}
+	unset := ""
+	value = configEnvVarPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		m := configEnvVarPattern.FindStringSubmatch(ref)
+		if v, ok := os.LookupEnv(m[1]); ok && (v != "" || m[2] == "") {
+			return v
+		}
+		if m[2] == "" {
+			unset = m[1]
+		}
+		return m[3]
+	})
+	if unset != "" {
+		TRACE.Println("Config option", option, "refers to unset environment variable", unset)
+		return "", false
+	}
+
+	// Other options: %(name)s
+	missing := ""
+	value = configVarPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		name := configVarPattern.FindStringSubmatch(ref)[1]
+		v, ok := c.lookup(name, depth+1)
+		if !ok {
+			missing = name
+		}
+		return v
+	})
+	if missing != "" {
+		ERROR.Println("Failed to interpolate config option", option, ": option not found:", missing)
+		return "", false
+	}
+
+	return stripQuotes(value), true
+}
+
+// envOverride returns the value of the environment variable overriding the
+// given option, if any.
+func (c *MergedConfig) envOverride(option string) (string, bool) {
+	if c.envPrefix == "" {
+		return "", false
+	}
+	return os.LookupEnv(c.envName(option))
+}
+
+// envName returns the name of the environment variable overriding the option.
+// e.g. "http.port" => "REVEL_HTTP_PORT"
+func (c *MergedConfig) envName(option string) string {
+	return c.envPrefix + strings.ToUpper(configEnvNameReplacer.Replace(option))
+}
+
+// envOption returns the registered option overridden by the environment
+// variable, if any.  The "*" segments of an option take the lowercased parts
+// of the variable name they match, e.g. REVEL_LOG_ACCESS_OUTPUT =>
+// log.access.output for the "log.*.output" option.
+func (c *MergedConfig) envOption(name string) (string, bool) {
+	for _, option := range configOptions {
+		if option.envPattern == nil && c.envName(option.Name) == name {
+			return option.Name, true
+		}
+	}
+
+	// The most specific pattern wins, as in findConfigOption.
+	var (
+		match *ConfigOption
+		key   string
+	)
+	for _, option := range configOptions {
+		if option.envPattern == nil || (match != nil && !moreSpecificConfigOption(option.Name, match.Name)) {
+			continue
+		}
+		captures := option.envPattern.FindStringSubmatch(strings.TrimPrefix(name, c.envPrefix))
+		if captures == nil {
+			continue
+		}
+		segments := strings.Split(option.Name, "*")
+		for i := 1; i < len(captures); i++ {
+			segments[i-1] += strings.ToLower(captures[i])
+		}
+		match, key = option, strings.Join(segments, "")
+	}
+	return key, match != nil
+}
+
+// configEnvPattern returns the regular expression matching the names of the
+// environment variables of an option pattern, without their prefix, and
+// capturing its "*" segments, e.g. "log.*.output" => ^LOG_([A-Z0-9_]+)_OUTPUT$
+func configEnvPattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(strings.ToUpper(configEnvNameReplacer.Replace(pattern)), "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, "([A-Z0-9_]+)") + "$")
+}
+
 func (c *MergedConfig) HasSection(section string) bool {
-	return c.config.HasSection(section)
+	return c.Raw().HasSection(section)
 }
 
 // Options returns all configuration option keys.
 // If a prefix is provided, then that is applied as a filter.
+//
+// Options only defined through environment variables are included when they
+// name a registered option (see RegisterConfigOptions), e.g. REVEL_MODULE_JOBS
+// => module.jobs for the "module.*" option.  Other variables with the prefix
+// are ignored, as their names cannot be mapped back reliably.
 func (c *MergedConfig) Options(prefix string) []string {
 	var options []string
-	keys, _ := c.config.Options(c.section)
+	keys, _ := c.Raw().Options(c.section)
+	known := make(map[string]bool)
 	for _, key := range keys {
+		known[c.envName(key)] = true
 		if strings.HasPrefix(key, prefix) {
 			options = append(options, key)
 		}
 	}
+
+	if c.envPrefix != "" {
+		for _, env := range os.Environ() {
+			name := strings.SplitN(env, "=", 2)[0]
+			if !strings.HasPrefix(name, c.envPrefix) || known[name] {
+				continue
+			}
+			key, found := c.envOption(name)
+			if found && strings.HasPrefix(key, prefix) {
+				options = append(options, key)
+			}
+		}
+	}
 	return options
 }
 
+// Bind populates the struct pointed to by ptr from the config.  Each field
+// tagged with `conf:"option.name"` receives the value of that option, parsed
+// according to the field type:
+//
+//     type DbConfig struct {
+//         Driver  string        `conf:"driver" default:"postgres"`
+//         Url     string        `conf:"url" required:"true"`
+//         Pool    int           `conf:"pool.size" default:"10"`
+//         Timeout time.Duration `conf:"timeout" default:"5s"`
+//         Hosts   []string      `conf:"hosts"` // comma separated
+//     }
+//
+//     var conf struct {
+//         Db DbConfig `conf:"db"` // binds db.driver, db.url, etc
+//     }
+//     err := revel.Config.Bind(&conf)
+//
+// Supported field types are strings, booleans, integers, floats,
+// time.Duration and slices of those, and nested structs, whose tag is a prefix
+// applied to the options of their own fields.  Options that are not set and
+// have no default leave the field unchanged, unless the field is required.
+//
+// Every missing or malformed option is reported, in a single *ConfigError.
+func (c *MergedConfig) Bind(ptr interface{}) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("revel: Bind requires a pointer to a struct, got %T", ptr)
+	}
+
+	confErr := &ConfigError{}
+	c.bindStruct(v.Elem(), "", confErr)
+	if len(confErr.Problems) > 0 {
+		return confErr
+	}
+	return nil
+}
+
+func (c *MergedConfig) bindStruct(v reflect.Value, prefix string, confErr *ConfigError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("conf")
+		if name == "" || name == "-" || field.PkgPath != "" {
+			continue
+		}
+		option := prefix + name
+
+		fieldValue := v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			c.bindStruct(fieldValue, option+".", confErr)
+			continue
+		}
+
+		value, found := c.String(option)
+		if !found {
+			value, found = field.Tag.Lookup("default")
+		}
+		if !found {
+			if field.Tag.Get("required") == "true" {
+				confErr.add(option, "required option is not set")
+			}
+			continue
+		}
+
+		if err := parseConfigValue(fieldValue, value); err != nil {
+			confErr.add(option, err.Error())
+		}
+	}
+}
+
+// parseConfigValue parses the string into the given value, according to its
+// type.  Slices are read as comma separated lists.
+func parseConfigValue(v reflect.Value, s string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("expected a duration (e.g. 1m30s), got %q", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, ok := configBoolStrings[strings.ToLower(s)]
+		if !ok {
+			return fmt.Errorf("expected a boolean, got %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a positive integer, got %q", s)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a number, got %q", s)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := parseConfigValue(slice.Index(i), item); err != nil {
+				return fmt.Errorf("item %d: %s", i+1, err)
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// ConfigError lists every problem found with the config options, e.g. by
+// MergedConfig.Bind.
+type ConfigError struct {
+	Problems []ConfigProblem
+}
+
+// ConfigProblem describes the problem found with a single option.
+type ConfigProblem struct {
+	Option  string // e.g. "db.pool.size"
+	Message string // e.g. "expected an integer, got \"ten\""
+}
+
+func (e *ConfigError) add(option, message string) {
+	e.Problems = append(e.Problems, ConfigProblem{option, message})
+}
+
+func (e *ConfigError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = p.Option + ": " + p.Message
+	}
+	return fmt.Sprintf("invalid configuration (%d problems):\n\t%s",
+		len(e.Problems), strings.Join(lines, "\n\t"))
+}
+
 // Helpers
 
+const (
+	maxConfigInterpolationDepth = 200
+
+	// The directory next to app.conf whose *.conf files are merged into it,
+	// in lexical order.
+	configOverlayDir = "conf.d"
+)
+
+var (
+	configVarPattern      = regexp.MustCompile(`%\(([a-zA-Z0-9_.\-]+)\)s`)                   // %(option)s
+	configEnvVarPattern   = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)(:-([^}]*))?\}`) // ${NAME}, ${NAME:-default}
+	configEnvNameReplacer = strings.NewReplacer(".", "_", "-", "_")
+	configIncludePattern  = regexp.MustCompile(`^include(\??)\s+"([^"]+)"$`) // include "file", include? "file"
+
+	// Strings accepted as boolean, as by github.com/robfig/config.
+	configBoolStrings = map[string]bool{
+		"t": true, "true": true, "y": true, "yes": true, "on": true, "1": true,
+		"f": false, "false": false, "n": false, "no": false, "off": false, "0": false,
+	}
+)
+
+// readConfigFile reads the given config file and the files it includes, from
+// SourceFS if it is set.  It returns the parsed config and the content of
+// every file read, which changes whenever any of them do.
+func readConfigFile(filename string) (*config.Config, string, error) {
+	loader := &configLoader{conf: config.NewDefault()}
+	if err := loader.readFile(filename, ""); err != nil {
+		return nil, "", err
+	}
+	return loader.conf, loader.content.String(), nil
+}
+
+// configLoader reads config files into a single config.Config, following their
+// include directives:
+//
+//     include "db.conf"      # fails if db.conf does not exist
+//     include? "local.conf"  # ignored if local.conf does not exist
+//     include "conf.d/*.conf"
+//
+// The included paths are relative to the including file, and may be patterns
+// (as accepted by path.Match), whose files are included in lexical order.  The
+// options of an included file belong to the section in which it is included,
+// until it starts a section of its own.  Options read later override the
+// earlier ones.
+type configLoader struct {
+	conf    *config.Config
+	content strings.Builder // The content of every file read, to detect changes
+	stack   []string        // The files being read, to detect include cycles
+}
+
+// readFile adds the sections and options of the given file to the config,
+// starting in the given section.  It follows the syntax of
+// github.com/robfig/config, plus the include directives.
+func (l *configLoader) readFile(filename, section string) error {
+	content, err := readSourceFile(filename)
+	if err != nil {
+		return &Error{
+			Title:       "Failed to read config file",
+			Path:        filename,
+			Description: err.Error(),
+		}
+	}
+	l.content.WriteString(filename + "\n")
+	l.content.Write(content)
+
+	l.stack = append(l.stack, filename)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+
+	var (
+		option string
+		lines  = strings.Split(string(content), "\n")
+	)
+	for n, line := range lines {
+		parseError := func(description string) *Error {
+			return &Error{
+				Title:       "Failed to parse config file",
+				Path:        filename,
+				Line:        n + 1,
+				Description: description,
+				SourceLines: lines,
+			}
+		}
+
+		text := strings.TrimRightFunc(stripConfigComments(line), unicode.IsSpace)
+		switch {
+		// Empty line and comments
+		case len(text) == 0, text[0] == '#', text[0] == ';':
+			continue
+
+		// New section. The [ must be at the start of the line
+		case text[0] == '[' && text[len(text)-1] == ']':
+			option = "" // reset multi-line value
+			section = strings.TrimSpace(text[1 : len(text)-1])
+			l.conf.AddSection(section)
+
+		// Continuation of multi-line value
+		case section != "" && option != "" && (text[0] == ' ' || text[0] == '\t'):
+			prev, _ := l.conf.RawString(section, option)
+			l.conf.AddOption(section, option, prev+"\n"+strings.TrimSpace(text))
+
+		// Include directive
+		case configIncludePattern.MatchString(text):
+			option = ""
+			m := configIncludePattern.FindStringSubmatch(text)
+			optional, name := m[1] == "?", m[2]
+			if !path.IsAbs(name) && !filepath.IsAbs(name) {
+				name = path.Join(path.Dir(filename), name)
+			}
+			if err := l.include(name, section, optional); err != nil {
+				if revelError, ok := err.(*Error); ok && revelError.Path != "" {
+					return err
+				}
+				return parseError(err.Error())
+			}
+
+		default:
+			i := strings.IndexAny(text, "=:")
+			if i <= 0 || text[0] == ' ' || text[0] == '\t' {
+				return parseError("could not parse line: " + text)
+			}
+			option = strings.TrimSpace(text[0:i])
+			l.conf.AddOption(section, option, strings.TrimSpace(text[i+1:]))
+		}
+	}
+	return nil
+}
+
+// include reads the file(s) matching the given name, starting in the given
+// section.  A missing file is an error unless optional is true.
+func (l *configLoader) include(name, section string, optional bool) error {
+	filenames := []string{name}
+	if strings.ContainsAny(name, "*?[") {
+		var err error
+		if filenames, err = globSource(name); err != nil {
+			return err
+		}
+		if len(filenames) == 0 && !optional {
+			return fmt.Errorf("no files match %s", name)
+		}
+	}
+
+	for _, filename := range filenames {
+		if !sourceFileExists(filename) {
+			if optional {
+				continue
+			}
+			return fmt.Errorf("included file %s does not exist", filename)
+		}
+		for _, parent := range l.stack {
+			if path.Clean(parent) == path.Clean(filename) {
+				return fmt.Errorf("include cycle: %s", strings.Join(append(l.stack, filename), " includes "))
+			}
+		}
+		if err := l.readFile(filename, section); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stripConfigComments removes a trailing comment, preceded by a space or tab.
+func stripConfigComments(l string) string {
+	for _, c := range []string{" ;", "\t;", " #", "\t#"} {
+		if i := strings.Index(l, c); i != -1 {
+			l = l[0:i]
+		}
+	}
+	return l
+}
+
 func stripQuotes(s string) string {
 	if s == "" {
 		return s
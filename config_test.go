@@ -0,0 +1,301 @@
+package revel
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newTestConfig() *MergedConfig {
+	c := NewEmptyConfig()
+	c.SetSection("dev")
+	c.SetEnvPrefix("REVEL_")
+	c.SetOption("http.port", "9000")
+	c.SetOption("db.url", "${TEST_DB_URL}")
+	c.SetOption("db.user", "${TEST_DB_USER:-revel}")
+	c.SetOption("db.dsn", "%(db.user)s@%(db.url)s")
+	c.SetOption("results.chunked", "false")
+	return c
+}
+
+func TestConfigEnvOverride(t *testing.T) {
+	c := newTestConfig()
+	if port := c.IntDefault("http.port", 0); port != 9000 {
+		t.Errorf("Expected http.port 9000, got %d", port)
+	}
+
+	t.Setenv("REVEL_HTTP_PORT", "8080")
+	t.Setenv("REVEL_RESULTS_CHUNKED", "on")
+	if port := c.IntDefault("http.port", 0); port != 8080 {
+		t.Errorf("Expected http.port overridden to 8080, got %d", port)
+	}
+	if !c.BoolDefault("results.chunked", false) {
+		t.Error("Expected results.chunked overridden to true")
+	}
+
+	// Without a prefix, the environment is ignored.
+	c.SetEnvPrefix("")
+	if port := c.IntDefault("http.port", 0); port != 9000 {
+		t.Errorf("Expected http.port 9000 without env prefix, got %d", port)
+	}
+}
+
+func TestConfigEnvInterpolation(t *testing.T) {
+	c := newTestConfig()
+	if url, found := c.String("db.url"); found {
+		t.Errorf("Expected db.url not found while TEST_DB_URL is unset, got %q", url)
+	}
+	if user := c.StringDefault("db.user", ""); user != "revel" {
+		t.Errorf("Expected db.user default revel, got %q", user)
+	}
+
+	t.Setenv("TEST_DB_URL", "localhost:5432")
+	t.Setenv("TEST_DB_USER", "rob")
+	if dsn := c.StringDefault("db.dsn", ""); dsn != "rob@localhost:5432" {
+		t.Errorf("Expected db.dsn rob@localhost:5432, got %q", dsn)
+	}
+}
+
+func TestConfigEnvOptions(t *testing.T) {
+	c := newTestConfig()
+	t.Setenv("REVEL_HTTP_PORT", "8080")
+	t.Setenv("REVEL_MODULE_JOBS", "github.com/revel/modules/jobs")
+	t.Setenv("REVEL_LOG_ACCESS_OUTPUT", "access.log")
+	t.Setenv("REVEL_I18N_DEFAULT_LANGUAGE", "fr")
+	// Not a registered option, so not listed.
+	t.Setenv("REVEL_DB_POOL", "10")
+
+	options := c.Options("")
+	sort.Strings(options)
+	expected := []string{"db.dsn", "db.url", "db.user", "http.port", "i18n.default_language",
+		"log.access.output", "module.jobs", "results.chunked"}
+	if len(options) != len(expected) {
+		t.Fatalf("Expected options %v, got %v", expected, options)
+	}
+	for i := range expected {
+		if options[i] != expected[i] {
+			t.Errorf("Expected options %v, got %v", expected, options)
+			break
+		}
+	}
+
+	if options := c.Options("module."); len(options) != 1 || options[0] != "module.jobs" {
+		t.Errorf("Expected options [module.jobs], got %v", options)
+	}
+	if options := c.Options("db.p"); len(options) != 0 {
+		t.Errorf("Expected no options, got %v", options)
+	}
+}
+
+type testDbConfig struct {
+	Driver  string        `conf:"driver" default:"postgres"`
+	Url     string        `conf:"url" required:"true"`
+	Pool    int           `conf:"pool.size" default:"10"`
+	Timeout time.Duration `conf:"timeout" default:"5s"`
+	Hosts   []string      `conf:"hosts"`
+	Ports   []int         `conf:"ports"`
+	Debug   bool          `conf:"debug"`
+	Ignored string
+}
+
+func TestConfigBind(t *testing.T) {
+	c := NewEmptyConfig()
+	c.SetSection("dev")
+	c.SetOption("app.name", "sample")
+	c.SetOption("db.url", "localhost:5432")
+	c.SetOption("db.pool.size", "20")
+	c.SetOption("db.hosts", "a, b,c")
+	c.SetOption("db.ports", "1,2")
+	c.SetOption("db.debug", "yes")
+
+	var conf struct {
+		Name string       `conf:"app.name"`
+		Db   testDbConfig `conf:"db"`
+	}
+	conf.Db.Ignored = "untouched"
+	if err := c.Bind(&conf); err != nil {
+		t.Fatal(err)
+	}
+
+	db := conf.Db
+	if conf.Name != "sample" || db.Driver != "postgres" || db.Url != "localhost:5432" ||
+		db.Pool != 20 || db.Timeout != 5*time.Second || !db.Debug || db.Ignored != "untouched" {
+		t.Errorf("Unexpected binding: %+v", conf)
+	}
+	if len(db.Hosts) != 3 || db.Hosts[1] != "b" || len(db.Ports) != 2 || db.Ports[1] != 2 {
+		t.Errorf("Unexpected slices: %v %v", db.Hosts, db.Ports)
+	}
+}
+
+func TestConfigBindErrors(t *testing.T) {
+	c := NewEmptyConfig()
+	c.SetSection("dev")
+	c.SetOption("db.pool.size", "ten")
+	c.SetOption("db.timeout", "5")
+	c.SetOption("db.ports", "1,x")
+
+	var conf struct {
+		Db testDbConfig `conf:"db"`
+	}
+	err := c.Bind(&conf)
+	confErr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("Expected a *ConfigError, got %T: %v", err, err)
+	}
+
+	expected := []string{"db.url", "db.pool.size", "db.timeout", "db.ports"}
+	if len(confErr.Problems) != len(expected) {
+		t.Fatalf("Expected problems with %v, got %s", expected, err)
+	}
+	for i, option := range expected {
+		if confErr.Problems[i].Option != option {
+			t.Errorf("Expected a problem with %s, got %s", option, confErr.Problems[i].Option)
+		}
+	}
+
+	if err := c.Bind(conf); err == nil {
+		t.Error("Expected an error binding a non-pointer")
+	}
+}
+
+func TestConfigRefresh(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) {
+		if err := os.WriteFile(filepath.Join(dir, "app.conf"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("[dev]\ncookie.domain = example.com\n")
+
+	oldConfPaths, oldConfig, oldHooks, oldDomain := ConfPaths, Config, configChangeHooks, CookieDomain
+	defer func() {
+		ConfPaths, Config, configChangeHooks, CookieDomain = oldConfPaths, oldConfig, oldHooks, oldDomain
+	}()
+
+	ConfPaths = []string{dir}
+	c, err := LoadConfig("app.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetSection("dev")
+	Config = c
+
+	hookRuns := 0
+	configChangeHooks = nil
+	OnConfigChange(func() { hookRuns++ })
+
+	if err := c.Refresh(); err != nil || hookRuns != 0 {
+		t.Errorf("Expected an unchanged file to be ignored, got %v and %d hook runs", err, hookRuns)
+	}
+
+	CookieDomain = "example.com"
+	write("[dev]\ncookie.domain = example.org\n")
+	if err := c.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if domain := c.StringDefault("cookie.domain", ""); domain != "example.org" || hookRuns != 1 {
+		t.Errorf("Expected cookie.domain example.org and 1 hook run, got %q and %d", domain, hookRuns)
+	}
+	if CookieDomain != "example.com" {
+		t.Errorf("Expected CookieDomain to be left as set by Init, got %q", CookieDomain)
+	}
+
+	write("[prod]\ncookie.domain = example.net\n")
+	if err := c.Refresh(); err == nil {
+		t.Error("Expected an error when the run mode disappears")
+	}
+	if domain := c.StringDefault("cookie.domain", ""); domain != "example.org" {
+		t.Errorf("Expected the previous options to be kept, got cookie.domain %q", domain)
+	}
+
+	if !c.WatchFile(filepath.Join(dir, "app.conf")) || !c.WatchFile(filepath.Join(dir, "conf.d", "db.conf")) {
+		t.Error("Expected app.conf and the files it may include to be watched")
+	}
+	if c.WatchFile(filepath.Join(dir, "routes")) {
+		t.Error("Expected the routes not to be watched")
+	}
+}
+
+func TestConfigInclude(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"app.conf": "app.name = sample\n" +
+			"[dev]\n" +
+			"include \"db.conf\"\n" +
+			"include? \"local.conf\"\n" +
+			"[prod]\n" +
+			"db.user = prod\n",
+		"db.conf":           "db.user = dev\ndb.pool = 5\n",
+		"conf.d/10-a.conf":  "db.pool = 10\n[dev]\ndb.pool = 10\n",
+		"conf.d/20-b.conf":  "[dev]\ndb.pool = 20\n",
+		"conf.d/readme.txt": "not a config file",
+	})
+
+	oldConfPaths := ConfPaths
+	defer func() { ConfPaths = oldConfPaths }()
+	ConfPaths = []string{dir}
+
+	c, err := LoadConfig("app.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetSection("dev")
+	if user := c.StringDefault("db.user", ""); user != "dev" {
+		t.Errorf("Expected db.user dev from db.conf, got %q", user)
+	}
+	if pool := c.IntDefault("db.pool", 0); pool != 20 {
+		t.Errorf("Expected db.pool 20 from conf.d/20-b.conf, got %d", pool)
+	}
+	c.SetSection("prod")
+	if pool := c.IntDefault("db.pool", 0); pool != 10 {
+		t.Errorf("Expected db.pool 10 from the DEFAULT section of conf.d/10-a.conf, got %d", pool)
+	}
+	if user := c.StringDefault("db.user", ""); user != "prod" {
+		t.Errorf("Expected db.user prod, got %q", user)
+	}
+}
+
+func TestConfigIncludeErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"missing.conf": "a = 1\n\ninclude \"nope.conf\"\n",
+		"cycle.conf":   "include \"cycle2.conf\"\n",
+		"cycle2.conf":  "b = 2\ninclude \"cycle.conf\"\n",
+		"broken.conf":  "include \"bad.conf\"\n",
+		"bad.conf":     "a = 1\nnot an option\n",
+	})
+
+	tests := []struct {
+		file, path string
+		line       int
+	}{
+		{"missing.conf", "missing.conf", 3},
+		{"cycle.conf", "cycle2.conf", 2},
+		{"broken.conf", "bad.conf", 2},
+	}
+	for _, test := range tests {
+		_, _, err := readConfigFile(filepath.Join(dir, test.file))
+		revelError, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%s: expected a *revel.Error, got %T: %v", test.file, err, err)
+			continue
+		}
+		if revelError.Path != filepath.Join(dir, test.path) || revelError.Line != test.line {
+			t.Errorf("%s: expected an error in %s:%d, got %s", test.file, test.path, test.line, err)
+		}
+	}
+}
+
+func writeConfigFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
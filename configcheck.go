@@ -0,0 +1,313 @@
+package revel
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConfigType is the type of value expected for a config option.
+type ConfigType string
+
+const (
+	ConfigString   ConfigType = "string"
+	ConfigInt      ConfigType = "int"
+	ConfigBool     ConfigType = "bool"
+	ConfigDuration ConfigType = "duration" // e.g. 1m30s
+	ConfigList     ConfigType = "list"     // comma separated
+)
+
+// The Go types used to check the values of each ConfigType.
+var configTypeSamples = map[ConfigType]reflect.Type{
+	ConfigString:   reflect.TypeOf(""),
+	ConfigInt:      reflect.TypeOf(0),
+	ConfigBool:     reflect.TypeOf(false),
+	ConfigDuration: reflect.TypeOf(time.Duration(0)),
+	ConfigList:     reflect.TypeOf([]string{}),
+}
+
+// ConfigOption describes an option read by Revel, a module or the app, so that
+// the config check can report the options that are misspelled, malformed or
+// missing.
+type ConfigOption struct {
+	// The option name.  A "*" segment matches any single segment, e.g.
+	// "module.*" or "log.*.output".
+	Name        string
+	Type        ConfigType
+	Default     string // As documentation, e.g. "9000"
+	Required    bool
+	Description string
+
+	envPattern *regexp.Regexp // Set for a pattern, see configEnvPattern
+}
+
+// configOptions holds the registered options, in the order of registration.
+var configOptions []*ConfigOption
+
+// RegisterConfigOptions declares the options read by a module or the app.
+// It should be called from an init() function, so that the options are known
+// by the time Init checks the config.
+//
+// Example:
+//
+//      func init() {
+//          revel.RegisterConfigOptions(
+//              revel.ConfigOption{Name: "db.url", Type: revel.ConfigString, Required: true,
+//                  Description: "The database connection URL"},
+//              revel.ConfigOption{Name: "db.pool.size", Type: revel.ConfigInt, Default: "10",
+//                  Description: "The maximum number of open connections"},
+//          )
+//      }
+func RegisterConfigOptions(options ...ConfigOption) {
+	for i := range options {
+		option := options[i]
+		if option.Type == "" {
+			option.Type = ConfigString
+		}
+		if strings.Contains(option.Name, "*") {
+			option.envPattern = configEnvPattern(option.Name)
+		}
+		configOptions = append(configOptions, &option)
+	}
+}
+
+// findConfigOption returns the registered option describing the given name, or
+// nil if there is none.  Exact names take precedence over patterns, and the
+// patterns with fewer "*" segments, then the longest, over the others, so that
+// the order of registration does not matter.
+func findConfigOption(name string) *ConfigOption {
+	var match *ConfigOption
+	for _, option := range configOptions {
+		if option.Name == name {
+			return option
+		}
+		if matchConfigOption(option.Name, name) && (match == nil || moreSpecificConfigOption(option.Name, match.Name)) {
+			match = option
+		}
+	}
+	return match
+}
+
+// moreSpecificConfigOption returns true if the pattern a is more specific than
+// the pattern b: it has fewer "*" segments, or as many but is longer.
+func moreSpecificConfigOption(a, b string) bool {
+	if wildcardsA, wildcardsB := strings.Count(a, "*"), strings.Count(b, "*"); wildcardsA != wildcardsB {
+		return wildcardsA < wildcardsB
+	}
+	return len(a) > len(b)
+}
+
+// matchConfigOption returns true if the option name matches the pattern, in
+// which a "*" segment matches any single segment.
+func matchConfigOption(pattern, name string) bool {
+	patternSegments, nameSegments := strings.Split(pattern, "."), strings.Split(name, ".")
+	if len(patternSegments) != len(nameSegments) {
+		return false
+	}
+	for i, segment := range patternSegments {
+		if segment != "*" && segment != nameSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckConfig compares the options set in Config (in the DEFAULT and run mode
+// sections, or in the environment) with the registered options.  It reports
+// every unknown option, malformed value and missing required option, or
+// returns nil if there is no problem.
+func CheckConfig() *ConfigError {
+	confErr := &ConfigError{}
+
+	options := Config.Options("")
+	sort.Strings(options)
+	set := make(map[string]bool)
+	for _, name := range options {
+		set[name] = true
+		option := findConfigOption(name)
+		if option == nil {
+			message := "unknown option"
+			if suggestion := suggestConfigOption(name); suggestion != "" {
+				message += ", did you mean " + suggestion + "?"
+			}
+			confErr.add(name, message)
+			continue
+		}
+
+		value, found := Config.String(name)
+		if !found {
+			continue
+		}
+		sample := reflect.New(configTypeSamples[option.Type]).Elem()
+		if err := parseConfigValue(sample, value); err != nil {
+			confErr.add(name, err.Error())
+		}
+	}
+
+	for _, option := range configOptions {
+		if option.Required && !strings.Contains(option.Name, "*") && !set[option.Name] {
+			confErr.add(option.Name, "required option is not set")
+		}
+	}
+
+	if len(confErr.Problems) == 0 {
+		return nil
+	}
+	return confErr
+}
+
+// checkConfig runs CheckConfig according to config.check: "warn" (the
+// default) logs the problems, "fail" returns them as an error, and "off" skips
+// the check.
+func checkConfig() *Error {
+	mode := Config.StringDefault("config.check", "warn")
+	if mode == "off" {
+		return nil
+	}
+
+	confErr := CheckConfig()
+	if confErr == nil {
+		return nil
+	}
+	if mode == "fail" {
+		return &Error{
+			Title:       "app.conf: Invalid configuration",
+			Description: confErr.Error(),
+		}
+	}
+	for _, problem := range confErr.Problems {
+		WARN.Printf("app.conf: %s: %s", problem.Option, problem.Message)
+	}
+	return nil
+}
+
+// suggestConfigOption returns the registered option closest to the given
+// unknown name, if it is close enough to be a likely misspelling.
+func suggestConfigOption(name string) string {
+	var (
+		suggestion string
+		best       = 3 // Suggest options up to 2 edits away.
+	)
+	for _, option := range configOptions {
+		if strings.Contains(option.Name, "*") {
+			continue
+		}
+		if d := editDistance(name, option.Name); d < best {
+			suggestion, best = option.Name, d
+		}
+	}
+	return suggestion
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// configEntry is a row of the config page.
+type configEntry struct {
+	Name, Value string
+	Source      string        // "app.conf", "environment" or "default"
+	Option      *ConfigOption // nil for unknown options
+	Problem     string
+}
+
+// configEntries lists the options set in Config, followed by the registered
+// options that are not set, with their defaults.  Secrets are masked.
+func configEntries() []configEntry {
+	problems := make(map[string]string)
+	if confErr := CheckConfig(); confErr != nil {
+		for _, problem := range confErr.Problems {
+			problems[problem.Option] = problem.Message
+		}
+	}
+
+	var entries []configEntry
+	options := Config.Options("")
+	sort.Strings(options)
+	set := make(map[string]bool)
+	for _, name := range options {
+		set[name] = true
+		entry := configEntry{
+			Name:    name,
+			Value:   Config.StringDefault(name, ""),
+			Source:  "app.conf",
+			Option:  findConfigOption(name),
+			Problem: problems[name],
+		}
+		if _, found := Config.envOverride(name); found {
+			entry.Source = "environment (" + Config.envName(name) + ")"
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, option := range configOptions {
+		if set[option.Name] || strings.Contains(option.Name, "*") {
+			continue
+		}
+		entries = append(entries, configEntry{
+			Name:    option.Name,
+			Value:   option.Default,
+			Source:  "default",
+			Option:  option,
+			Problem: problems[option.Name],
+		})
+	}
+
+	for i, entry := range entries {
+		if isSecretConfigOption(entry.Name) && entry.Value != "" {
+			entries[i].Value = "********"
+		}
+	}
+	return entries
+}
+
+// isSecretConfigOption returns true for options that should not be displayed.
+func isSecretConfigOption(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"secret", "password", "passwd", "token", "key"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigPageFilter serves a page listing the effective configuration, with the
+// description of each option and the problems found by CheckConfig, at the
+// path given by config.page ("/@config" by default).  It is added in front of
+// the Filters automatically in dev mode.
+var ConfigPageFilter = func(c *Controller, fc []Filter) {
+	if page := Config.StringDefault("config.page", "/@config"); page == "" || c.Request.URL.Path != page {
+		fc[0](c, fc[1:])
+		return
+	}
+
+	c.RenderArgs["RunMode"] = RunMode
+	c.RenderArgs["Entries"] = configEntries()
+	c.Result = c.RenderTemplate("config/page.html")
+}
+
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "config.check", Default: "warn", Description: `"warn", "fail" or "off": what to do about the problems found in app.conf`},
+		ConfigOption{Name: "config.page", Default: "/@config", Description: "The path of this page, in dev mode, or empty to disable it"},
+	)
+}
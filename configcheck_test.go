@@ -0,0 +1,108 @@
+package revel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckConfig(t *testing.T) {
+	oldConfig, oldOptions := Config, configOptions
+	defer func() { Config, configOptions = oldConfig, oldOptions }()
+
+	configOptions = nil
+	RegisterConfigOptions(
+		ConfigOption{Name: "session.expires"},
+		ConfigOption{Name: "http.port", Type: ConfigInt},
+		ConfigOption{Name: "http.shutdown.timeout", Type: ConfigDuration},
+		ConfigOption{Name: "module.*"},
+		ConfigOption{Name: "db.url", Required: true},
+	)
+
+	Config = NewEmptyConfig()
+	Config.SetSection("dev")
+	Config.SetOption("sesion.expires", "1h")
+	Config.SetOption("http.port", "nine thousand")
+	Config.SetOption("http.shutdown.timeout", "10s")
+	Config.SetOption("module.static", "github.com/revel/modules/static")
+
+	confErr := CheckConfig()
+	if confErr == nil {
+		t.Fatal("Expected problems with the config")
+	}
+	expected := map[string]string{
+		"sesion.expires": "unknown option, did you mean session.expires?",
+		"http.port":      `expected an integer, got "nine thousand"`,
+		"db.url":         "required option is not set",
+	}
+	if len(confErr.Problems) != len(expected) {
+		t.Errorf("Expected %d problems, got %s", len(expected), confErr)
+	}
+	for _, problem := range confErr.Problems {
+		if expected[problem.Option] != problem.Message {
+			t.Errorf("%s: expected %q, got %q", problem.Option, expected[problem.Option], problem.Message)
+		}
+	}
+
+	Config.SetOption("config.check", "fail")
+	if err := checkConfig(); err == nil {
+		t.Error("Expected an error with config.check = fail")
+	}
+	Config.SetOption("config.check", "warn")
+	if err := checkConfig(); err != nil {
+		t.Errorf("Expected no error with config.check = warn, got %s", err)
+	}
+}
+
+func TestFindConfigOption(t *testing.T) {
+	oldOptions := configOptions
+	defer func() { configOptions = oldOptions }()
+
+	configOptions = nil
+	RegisterConfigOptions(
+		ConfigOption{Name: "test.*.*"},
+		ConfigOption{Name: "test.*.name"},
+		ConfigOption{Name: "test.db.*"},
+		ConfigOption{Name: "test.db.url"},
+	)
+	for name, expected := range map[string]string{
+		"test.db.url":    "test.db.url",
+		"test.db.user":   "test.db.*",
+		"test.app.name":  "test.*.name",
+		"test.app.other": "test.*.*",
+	} {
+		if option := findConfigOption(name); option == nil || option.Name != expected {
+			t.Errorf("Expected %s to be described by %s, got %v", name, expected, option)
+		}
+	}
+	if option := findConfigOption("test.db"); option != nil {
+		t.Errorf("Expected no option for test.db, got %s", option.Name)
+	}
+}
+
+func TestConfigPageFilter(t *testing.T) {
+	startFakeBookingApp()
+
+	req, _ := http.NewRequest("GET", "/@config", nil)
+	resp := httptest.NewRecorder()
+	c := NewController(NewRequest(req), NewResponse(resp))
+	ConfigPageFilter(c, []Filter{func(c *Controller, fc []Filter) {
+		t.Error("Expected the config page to be served by ConfigPageFilter")
+	}})
+	if c.Result == nil {
+		t.Fatal("Expected a result")
+	}
+	c.Result.Apply(c.Request, c.Response)
+
+	body := resp.Body.String()
+	for _, s := range []string{"http.port", "The port to listen on", "db.driver", "unknown option"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Expected the config page to contain %q:\n%s", s, body)
+		}
+	}
+	secret := Config.StringDefault("app.secret", "")
+	if strings.Contains(body, `<td class="value">`+secret+"</td>") {
+		t.Error("Expected app.secret to be masked")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -31,10 +32,11 @@ type Controller struct {
 	Args       map[string]interface{} // Per-request scratch space.
 	RenderArgs map[string]interface{} // Args passed to the template.
 	Validation *Validation            // Data validation helpers
+	Log        *slog.Logger           // AppLog with the request ID, client IP and action (see RequestLog)
 }
 
 func NewController(req *Request, resp *Response) *Controller {
-	return &Controller{
+	c := &Controller{
 		Request:  req,
 		Response: resp,
 		Params:   new(Params),
@@ -43,7 +45,9 @@ func NewController(req *Request, resp *Response) *Controller {
 			"RunMode": RunMode,
 			"DevMode": DevMode,
 		},
+		Log: AppLog,
 	}
+	return c
 }
 
 // FlashParams serializes the contents of Controller.Params to the Flash
@@ -278,16 +282,17 @@ func (c *Controller) Message(message string, args ...interface{}) (value string)
 func (c *Controller) SetAction(controllerName, methodName string) error {
 
 	// Look up the controller and method types.
-	var ok bool
-	if c.Type, ok = controllers[strings.ToLower(controllerName)]; !ok {
-		return errors.New("revel/controller: failed to find controller " + controllerName)
-	}
-	if c.MethodType = c.Type.Method(methodName); c.MethodType == nil {
-		return errors.New("revel/controller: failed to find action " + methodName)
+	var err error
+	if c.Type, c.MethodType, err = findAction(controllerName, methodName); err != nil {
+		return err
 	}
 
 	c.Name, c.MethodName = c.Type.Type.Name(), c.MethodType.Name
 	c.Action = c.Name + "." + c.MethodName
+	if c.Log == nil {
+		c.Log = AppLog
+	}
+	c.Log = c.Log.With("action", c.Action)
 
 	// Instantiate the controller.
 	c.AppController = initNewAppController(c.Type, c).Interface()
@@ -295,6 +300,20 @@ func (c *Controller) SetAction(controllerName, methodName string) error {
 	return nil
 }
 
+// findAction returns the types of the controller and of its action method,
+// without instantiating the controller, e.g. to reverse a route.
+func findAction(controllerName, methodName string) (*ControllerType, *MethodType, error) {
+	controllerType, ok := controllers[strings.ToLower(controllerName)]
+	if !ok {
+		return nil, nil, errors.New("revel/controller: failed to find controller " + controllerName)
+	}
+	methodType := controllerType.Method(methodName)
+	if methodType == nil {
+		return nil, nil, errors.New("revel/controller: failed to find action " + methodName)
+	}
+	return controllerType, methodType, nil
+}
+
 // This is a helper that initializes (zeros) a new app controller value.
 // Specifically, it sets all *revel.Controller embedded types to the provided controller.
 // Returns a value representing a pointer to the new app controller.
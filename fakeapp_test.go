@@ -47,14 +47,18 @@ func (c Static) Serve(prefix, filepath string) Result {
 	}
 
 	fname := path.Join(basePath, prefix, filepath)
-	file, err := os.Open(fname)
+	file, err := OpenFile(fname)
 	if os.IsNotExist(err) {
 		return c.NotFound("")
 	} else if err != nil {
 		WARN.Printf("Problem opening file (%s): %s ", fname, err)
 		return c.NotFound("This was found but not sure why we couldn't open it.")
 	}
-	return c.RenderFile(file, "")
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return c.NotFound("")
+	}
+	return c.RenderBinary(file, path.Base(fname), "", fileInfo.ModTime())
 }
 
 func startFakeBookingApp() {
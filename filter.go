@@ -5,7 +5,9 @@ type Filter func(c *Controller, filterChain []Filter)
 // Filters is the default set of global filters.
 // It may be set by the application on initialization.
 var Filters = []Filter{
+	AccessLogFilter,         // Log the requests to log.access.output.
 	PanicFilter,             // Recover from panics and display an error page instead.
+	HealthFilter,            // Serve the health and readiness endpoints.
 	RouterFilter,            // Use the routing table to select the right Action.
 	FilterConfiguringFilter, // A hook for adding or removing per-Action filters.
 	ParamsFilter,            // Parse parameters into Controller.Params.
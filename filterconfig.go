@@ -202,6 +202,9 @@ func FilterEq(a, b Filter) bool {
 // filter chain for the action being invoked.
 func FilterConfiguringFilter(c *Controller, fc []Filter) {
 	if newChain := getOverrideChain(c.Name, c.Action); newChain != nil {
+		if SpanFromContext(c.Request.Context()) != nil {
+			newChain = traceFilters(newChain)
+		}
 		newChain[0](c, newChain[1:])
 		return
 	}
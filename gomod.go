@@ -0,0 +1,266 @@
+package revel
+
+import (
+	"bufio"
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// goModule is the subset of a go.mod file needed to find the source of the
+// packages it depends on: the module path, the required versions and the
+// replace directives.
+type goModule struct {
+	Path    string                      // e.g. "corp/sample"
+	Dir     string                      // e.g. "/home/rob/code/sample", containing go.mod
+	Require map[string]string           // module path => version
+	Replace map[string]goModReplacement // "path" or "path@version" => replacement
+}
+
+// goModReplacement is the right hand side of a replace directive.  If Version
+// is empty, Path is a directory (relative to the go.mod file, or absolute).
+type goModReplacement struct {
+	Path, Version string
+}
+
+// findGoMod returns the go.mod governing the given directory, by looking in it
+// and its parents.  Returns "" if there is none, or if module mode has been
+// disabled with GO111MODULE=off.
+func findGoMod(dir string) string {
+	if os.Getenv("GO111MODULE") == "off" {
+		return ""
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !fi.IsDir() {
+			return filepath.Join(dir, "go.mod")
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// parseGoMod reads the module, require and replace directives of a go.mod file.
+func parseGoMod(filename string) (*goModule, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mod := &goModule{
+		Dir:     filepath.Dir(filename),
+		Require: make(map[string]string),
+		Replace: make(map[string]goModReplacement),
+	}
+
+	var (
+		block   string // the verb of the enclosing "verb ( ... )" block, if any
+		lineNum int
+		scanner = bufio.NewScanner(file)
+	)
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i != -1 {
+			line = line[:i]
+		}
+		fields, err := goModFields(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", filename, lineNum, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		if block != "" {
+			if fields[0] == ")" {
+				block = ""
+				continue
+			}
+			fields = append([]string{block}, fields...)
+		} else if len(fields) == 2 && fields[1] == "(" {
+			block = fields[0]
+			continue
+		}
+
+		switch fields[0] {
+		case "module":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%s:%d: usage: module path", filename, lineNum)
+			}
+			mod.Path = fields[1]
+		case "require":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("%s:%d: usage: require module/path v1.2.3", filename, lineNum)
+			}
+			mod.Require[fields[1]] = fields[2]
+		case "replace":
+			arrow := -1
+			for i, field := range fields {
+				if field == "=>" {
+					arrow = i
+				}
+			}
+			var from, to []string
+			if arrow != -1 {
+				from, to = fields[1:arrow], fields[arrow+1:]
+			}
+			if len(from) < 1 || len(from) > 2 || len(to) < 1 || len(to) > 2 {
+				return nil, fmt.Errorf("%s:%d: usage: replace module/path [v1.2.3] => other/module v1.4 | ../local/dir",
+					filename, lineNum)
+			}
+			key := from[0]
+			if len(from) == 2 {
+				key += "@" + from[1]
+			}
+			replacement := goModReplacement{Path: to[0]}
+			if len(to) == 2 {
+				replacement.Version = to[1]
+			}
+			mod.Replace[key] = replacement
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if mod.Path == "" {
+		return nil, fmt.Errorf("%s: no module directive found", filename)
+	}
+	return mod, nil
+}
+
+// goModFields splits a go.mod line into its fields, unquoting quoted strings.
+func goModFields(line string) ([]string, error) {
+	var fields []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		if line[0] == '"' || line[0] == '`' {
+			prefix, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string: %s", line)
+			}
+			field, _ := strconv.Unquote(prefix)
+			fields = append(fields, field)
+			line = line[len(prefix):]
+			continue
+		}
+		end := strings.IndexFunc(line, unicode.IsSpace)
+		if end == -1 {
+			end = len(line)
+		}
+		fields = append(fields, line[:end])
+		line = line[end:]
+	}
+	return fields, nil
+}
+
+// packageDir returns the directory holding the source of the given import
+// path, as resolved by this module: either within the main module, within a
+// replacement directory, or within the module cache.
+func (mod *goModule) packageDir(importPath string) (string, error) {
+	if rest, ok := trimModulePath(importPath, mod.Path); ok {
+		return filepath.Join(mod.Dir, filepath.FromSlash(rest)), nil
+	}
+
+	// Find the longest required (or replaced) module path containing the package.
+	var modPath string
+	for candidate := range mod.Require {
+		if _, ok := trimModulePath(importPath, candidate); ok && len(candidate) > len(modPath) {
+			modPath = candidate
+		}
+	}
+	for key := range mod.Replace {
+		candidate := strings.SplitN(key, "@", 2)[0]
+		if _, ok := trimModulePath(importPath, candidate); ok && len(candidate) > len(modPath) {
+			modPath = candidate
+		}
+	}
+	if modPath == "" {
+		return "", fmt.Errorf("no required module provides package %s in %s",
+			importPath, filepath.Join(mod.Dir, "go.mod"))
+	}
+	rest, _ := trimModulePath(importPath, modPath)
+	version := mod.Require[modPath]
+
+	// A replace directive for the specific version takes precedence.
+	replacement, replaced := mod.Replace[modPath+"@"+version]
+	if !replaced {
+		replacement, replaced = mod.Replace[modPath]
+	}
+	if replaced {
+		if replacement.Version == "" {
+			dir := filepath.FromSlash(replacement.Path)
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(mod.Dir, dir)
+			}
+			return filepath.Join(dir, filepath.FromSlash(rest)), nil
+		}
+		modPath, version = replacement.Path, replacement.Version
+	}
+	if version == "" {
+		return "", fmt.Errorf("module %s is replaced but not required in %s",
+			modPath, filepath.Join(mod.Dir, "go.mod"))
+	}
+
+	dir, err := moduleCacheDir(modPath, version)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(rest)), nil
+}
+
+// trimModulePath returns the path of the package relative to the module root,
+// and whether the package belongs to the module at all.
+func trimModulePath(importPath, modPath string) (string, bool) {
+	if importPath == modPath {
+		return "", true
+	}
+	if strings.HasPrefix(importPath, modPath+"/") {
+		return importPath[len(modPath)+1:], true
+	}
+	return "", false
+}
+
+// moduleCacheDir returns the directory where the go command extracts the given
+// module version, e.g. $GOPATH/pkg/mod/github.com/!rob!fig/config@v1.0.0
+func moduleCacheDir(modPath, version string) (string, error) {
+	cache := os.Getenv("GOMODCACHE")
+	if cache == "" {
+		gopaths := filepath.SplitList(build.Default.GOPATH)
+		if len(gopaths) == 0 {
+			return "", fmt.Errorf("neither GOMODCACHE nor GOPATH is set")
+		}
+		cache = filepath.Join(gopaths[0], "pkg", "mod")
+	}
+	dir := filepath.Join(cache, filepath.FromSlash(escapeModulePath(modPath)+"@"+escapeModulePath(version)))
+	if !DirExists(dir) {
+		return "", fmt.Errorf("module %s@%s is not in the module cache (%s); run 'go mod download'",
+			modPath, version, dir)
+	}
+	return dir, nil
+}
+
+// escapeModulePath applies the case-encoding used by the module cache, which
+// replaces every upper case letter with an exclamation mark followed by the
+// lower case letter.
+func escapeModulePath(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if 'A' <= r && r <= 'Z' {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package revel
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testGoMod = `module corp/sample
+
+go 1.21
+
+require (
+	github.com/revel/revel v0.13.0
+	github.com/revel/modules v0.13.0 // indirect
+	github.com/Masterminds/squirrel v1.5.0
+)
+
+require golang.org/x/net v0.1.0
+
+replace github.com/revel/revel => ../revel
+
+replace (
+	github.com/revel/modules v0.13.0 => github.com/corp/modules v0.14.0
+	"github.com/Masterminds/squirrel" => /opt/squirrel
+)
+`
+
+func TestGoModPackageDir(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "revel-gomod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	appDir := filepath.Join(tmp, "sample")
+	cacheDir := filepath.Join(tmp, "cache")
+	for _, dir := range []string{
+		appDir,
+		filepath.Join(cacheDir, "github.com", "corp", "modules@v0.14.0"),
+		filepath.Join(cacheDir, "golang.org", "x", "net@v0.1.0"),
+	} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(appDir, "go.mod"), []byte(testGoMod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOMODCACHE", cacheDir)
+	t.Setenv("GO111MODULE", "")
+
+	goModFile := findGoMod(filepath.Join(appDir, "app", "controllers"))
+	if goModFile != filepath.Join(appDir, "go.mod") {
+		t.Fatalf("Expected to find go.mod in %s, got %q", appDir, goModFile)
+	}
+	mod, err := parseGoMod(goModFile)
+	if err != nil {
+		t.Fatal("Failed to parse go.mod:", err)
+	}
+	eq(t, "Path", mod.Path, "corp/sample")
+
+	testCases := map[string]string{
+		"corp/sample":                     appDir,
+		"corp/sample/app/controllers":     filepath.Join(appDir, "app", "controllers"),
+		"github.com/revel/revel":          filepath.Join(tmp, "revel"),
+		"github.com/revel/revel/cache":    filepath.Join(tmp, "revel", "cache"),
+		"github.com/revel/modules/static": filepath.Join(cacheDir, "github.com", "corp", "modules@v0.14.0", "static"),
+		"github.com/Masterminds/squirrel": "/opt/squirrel",
+		"golang.org/x/net/websocket":      filepath.Join(cacheDir, "golang.org", "x", "net@v0.1.0", "websocket"),
+	}
+	for importPath, expected := range testCases {
+		actual, err := mod.packageDir(importPath)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", importPath, err)
+			continue
+		}
+		eq(t, importPath, actual, expected)
+	}
+
+	if _, err := mod.packageDir("github.com/not/required"); err == nil {
+		t.Error("Expected an error for a package not provided by any required module")
+	}
+}
+
+func TestEscapeModulePath(t *testing.T) {
+	eq(t, "escaped", escapeModulePath("github.com/Masterminds/squirrel"), "github.com/!masterminds/squirrel")
+}
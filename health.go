@@ -0,0 +1,185 @@
+package revel
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "health.path", Default: "/_health", Description: "The path of the health endpoint, or empty to disable it"},
+		ConfigOption{Name: "health.ready.path", Default: "/_ready", Description: "The path of the readiness endpoint, or empty to disable it"},
+		ConfigOption{Name: "health.timeout", Type: ConfigDuration, Default: "5s", Description: "How long a health check may run before it fails"},
+	)
+}
+
+// HealthCheck checks a dependency of the app, e.g. a database connection,
+// returning nil if it is healthy or else the problem.
+type HealthCheck func() error
+
+type namedHealthCheck struct {
+	name      string
+	check     HealthCheck
+	readiness bool // Only run by the readiness endpoint
+}
+
+var (
+	healthChecks      []namedHealthCheck
+	healthChecksMutex sync.Mutex
+
+	shuttingDown atomic.Bool // true once the server has started draining
+)
+
+// RegisterHealthCheck registers a check run by both the health and the
+// readiness endpoints (see HealthFilter): a failure means that the app should
+// be restarted.  A check registered under an existing name replaces it.
+func RegisterHealthCheck(name string, check HealthCheck) {
+	registerHealthCheck(namedHealthCheck{name, check, false})
+}
+
+// RegisterReadinessCheck registers a check run by the readiness endpoint only
+// (see HealthFilter): a failure means that the app should not be sent
+// requests for now, e.g. because the database is unreachable.  A check
+// registered under an existing name replaces it.
+func RegisterReadinessCheck(name string, check HealthCheck) {
+	registerHealthCheck(namedHealthCheck{name, check, true})
+}
+
+func registerHealthCheck(check namedHealthCheck) {
+	healthChecksMutex.Lock()
+	defer healthChecksMutex.Unlock()
+	for i, registered := range healthChecks {
+		if registered.name == check.name {
+			healthChecks[i] = check
+			return
+		}
+	}
+	healthChecks = append(healthChecks, check)
+}
+
+// HealthReport is the JSON body served by the health endpoints.
+type HealthReport struct {
+	Status string                       `json:"status"` // "ok", "failed" or "shutting down"
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+// HealthCheckResult is the outcome of one check in a HealthReport.
+type HealthCheckResult struct {
+	Status    string  `json:"status"` // "ok" or "failed"
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// CheckHealth runs the health checks, or the readiness checks too, in
+// parallel.  A check running longer than the timeout fails.  The readiness
+// fails while the server is shutting down.
+func CheckHealth(readiness bool, timeout time.Duration) HealthReport {
+	healthChecksMutex.Lock()
+	var checks []namedHealthCheck
+	for _, check := range healthChecks {
+		if readiness || !check.readiness {
+			checks = append(checks, check)
+		}
+	}
+	healthChecksMutex.Unlock()
+
+	var (
+		report = HealthReport{Status: "ok", Checks: make(map[string]HealthCheckResult)}
+		mutex  sync.Mutex
+		wg     sync.WaitGroup
+	)
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check namedHealthCheck) {
+			defer wg.Done()
+			result := runHealthCheck(check.check, timeout)
+			mutex.Lock()
+			report.Checks[check.name] = result
+			mutex.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	names := make([]string, 0, len(report.Checks))
+	for name := range report.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if result := report.Checks[name]; result.Status != "ok" {
+			WARN.Printf("Health check %s failed: %s", name, result.Error)
+			report.Status = "failed"
+		}
+	}
+	if readiness && shuttingDown.Load() {
+		report.Status = "shutting down"
+	}
+	return report
+}
+
+// runHealthCheck runs the check, recovering from a panic.  A check that times
+// out keeps running in the background.
+func runHealthCheck(check HealthCheck, timeout time.Duration) HealthCheckResult {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				done <- fmt.Errorf("panic: %v", err)
+			}
+		}()
+		done <- check()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	result := HealthCheckResult{
+		Status:    "ok",
+		LatencyMs: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		result.Status, result.Error = "failed", err.Error()
+	}
+	return result
+}
+
+// HealthFilter serves the health endpoint, at health.path ("/_health" by
+// default), and the readiness endpoint, at health.ready.path ("/_ready" by
+// default), for load balancers and orchestrators.  Each returns a JSON
+// HealthReport, with the status 200 if every check passed or else 503.
+// Setting a path to the empty string disables the endpoint.
+//
+// The endpoints are served before routing, so they need no route, session
+// nor interceptor.  Checks are registered with RegisterHealthCheck and
+// RegisterReadinessCheck; the cache module contributes one.
+var HealthFilter = func(c *Controller, fc []Filter) {
+	var readiness bool
+	switch path := c.Request.URL.Path; {
+	case path != "" && path == Config.StringDefault("health.path", "/_health"):
+	case path != "" && path == Config.StringDefault("health.ready.path", "/_ready"):
+		readiness = true
+	default:
+		fc[0](c, fc[1:])
+		return
+	}
+
+	timeout, err := durationOption("health.timeout", 5*time.Second)
+	if err != nil {
+		timeout = 5 * time.Second
+	}
+	report := CheckHealth(readiness, timeout)
+	if report.Status != "ok" {
+		c.Response.Status = http.StatusServiceUnavailable
+	}
+	c.Response.Out.Header().Set("Cache-Control", "no-store")
+	c.Result = c.RenderJson(report)
+}
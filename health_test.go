@@ -0,0 +1,99 @@
+package revel
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthFilter(t *testing.T) {
+	startFakeBookingApp()
+	oldChecks := healthChecks
+	defer func() {
+		healthChecks = oldChecks
+		shuttingDown.Store(false)
+	}()
+	healthChecks = nil
+
+	var dbErr error
+	RegisterHealthCheck("app", func() error { return nil })
+	RegisterReadinessCheck("db", func() error { return dbErr })
+	RegisterReadinessCheck("slow", func() error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	serve := func(path string) (int, HealthReport) {
+		req, _ := http.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		c := NewController(NewRequest(req), NewResponse(resp))
+		HealthFilter(c, []Filter{func(c *Controller, fc []Filter) {
+			t.Errorf("Expected %s to be served by HealthFilter", path)
+		}})
+		c.Result.Apply(c.Request, c.Response)
+
+		var report HealthReport
+		if err := json.Unmarshal(resp.Body.Bytes(), &report); err != nil {
+			t.Fatalf("Failed to decode the report of %s: %s\n%s", path, err, resp.Body)
+		}
+		return resp.Code, report
+	}
+
+	// The health endpoint only runs the health checks.
+	code, report := serve("/_health")
+	if code != http.StatusOK || report.Status != "ok" || len(report.Checks) != 1 || report.Checks["app"].Status != "ok" {
+		t.Errorf("Expected a healthy report of the app check, got %d %+v", code, report)
+	}
+
+	code, report = serve("/_ready")
+	if code != http.StatusOK || report.Status != "ok" || len(report.Checks) != 3 {
+		t.Errorf("Expected a ready report of 3 checks, got %d %+v", code, report)
+	}
+	if latency := report.Checks["slow"].LatencyMs; latency < 10 {
+		t.Errorf("Expected the latency of the slow check to be at least 10ms, got %f", latency)
+	}
+
+	dbErr = errors.New("connection refused")
+	code, report = serve("/_ready")
+	if code != http.StatusServiceUnavailable || report.Status != "failed" ||
+		report.Checks["db"].Status != "failed" || report.Checks["db"].Error != "connection refused" {
+		t.Errorf("Expected the failed db check to be reported, got %d %+v", code, report)
+	}
+	if code, _ = serve("/_health"); code != http.StatusOK {
+		t.Errorf("Expected a failed readiness check not to fail the health endpoint, got %d", code)
+	}
+
+	dbErr = nil
+	shuttingDown.Store(true)
+	code, report = serve("/_ready")
+	if code != http.StatusServiceUnavailable || report.Status != "shutting down" {
+		t.Errorf("Expected the app not to be ready while shutting down, got %d %+v", code, report)
+	}
+
+	// Other paths go through.
+	req, _ := http.NewRequest("GET", "/hotels", nil)
+	c := NewController(NewRequest(req), NewResponse(httptest.NewRecorder()))
+	passed := false
+	HealthFilter(c, []Filter{func(c *Controller, fc []Filter) { passed = true }})
+	if !passed {
+		t.Error("Expected /hotels to go through HealthFilter")
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	result := runHealthCheck(func() error {
+		time.Sleep(time.Second)
+		return nil
+	}, 10*time.Millisecond)
+	if result.Status != "failed" || result.Error != "timed out after 10ms" {
+		t.Errorf("Expected the check to time out, got %+v", result)
+	}
+
+	result = runHealthCheck(func() error { panic("boom") }, time.Second)
+	if result.Status != "failed" || result.Error != "panic: boom" {
+		t.Errorf("Expected the panic to fail the check, got %+v", result)
+	}
+}
@@ -2,6 +2,7 @@ package revel
 
 import (
 	"bytes"
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"sort"
@@ -14,11 +15,17 @@ import (
 
 type Request struct {
 	*http.Request
+	ID              string // The X-Request-Id header, or a random ID
 	ContentType     string
 	Format          string // "html", "xml", "json", or "txt"
 	AcceptLanguages AcceptLanguages
 	Locale          string
 	Websocket       *websocket.Conn
+
+	// The verified chains of the client certificate, from the client
+	// certificate itself to a CA of http.ssl.clientca, when the client
+	// authenticated with one over TLS.
+	VerifiedChains [][]*x509.Certificate
 }
 
 type Response struct {
@@ -33,12 +40,35 @@ func NewResponse(w http.ResponseWriter) *Response {
 }
 
 func NewRequest(r *http.Request) *Request {
-	return &Request{
+	req := &Request{
 		Request:         r,
+		ID:              requestID(r),
 		ContentType:     ResolveContentType(r),
 		Format:          ResolveFormat(r),
 		AcceptLanguages: ResolveAcceptLanguage(r),
 	}
+	if r.TLS != nil {
+		req.VerifiedChains = r.TLS.VerifiedChains
+	}
+	return req
+}
+
+// requestID returns the X-Request-Id header set by a proxy, if reasonable, or
+// else a random ID.
+func requestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-Id"); id != "" && len(id) <= 128 && !strings.ContainsAny(id, "\r\n") {
+		return id
+	}
+	return newTraceID(8)
+}
+
+// ClientCertificate returns the verified client certificate, or nil if the
+// client did not authenticate with one.
+func (req *Request) ClientCertificate() *x509.Certificate {
+	if len(req.VerifiedChains) == 0 || len(req.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return req.VerifiedChains[0][0]
 }
 
 // Write the header (for now, just the status code).
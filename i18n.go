@@ -88,7 +88,7 @@ func parseLocale(locale string) (language, region string) {
 func loadMessages(path string) {
 	messages = make(map[string]*config.Config)
 
-	if error := filepath.Walk(path, loadMessageFile); error != nil && !os.IsNotExist(error) {
+	if error := walkSource(path, loadMessageFile); error != nil && !os.IsNotExist(error) {
 		ERROR.Println("Error reading messages files:", error)
 	}
 }
@@ -126,7 +126,7 @@ func loadMessageFile(path string, info os.FileInfo, osError error) error {
 }
 
 func parseMessagesFile(path string) (messageConfig *config.Config, error error) {
-	messageConfig, error = config.ReadDefault(path)
+	messageConfig, _, error = readConfigFile(path)
 	return
 }
 
@@ -136,6 +136,11 @@ func parseLocaleFromFileName(file string) string {
 }
 
 func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "i18n.default_language", Description: "The language used when none is requested"},
+		ConfigOption{Name: "i18n.cookie", Description: "The name of the cookie holding the locale"},
+	)
+
 	OnAppStart(func() {
 		loadMessages(filepath.Join(BasePath, messageFilesDirectory))
 	})
@@ -29,6 +29,7 @@ func ActionInvoker(c *Controller, _ []Filter) {
 		methodArgs = append(methodArgs, boundArg)
 	}
 
+	defer withSpan(c, "action "+c.Action)()
 	var resultValue reflect.Value
 	if methodValue.Type().IsVariadic() {
 		resultValue = methodValue.CallSlice(methodArgs)[0]
@@ -0,0 +1,218 @@
+package revel
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "http.listeners", Type: ConfigList, Description: "The names of the listeners to serve on, instead of http.addr and http.port"},
+		ConfigOption{Name: "http.listener.*.addr", Description: "The address of a listener, e.g. :443 or unix:/tmp/app.sock"},
+		ConfigOption{Name: "http.listener.*.ssl", Type: ConfigBool, Default: "false", Description: "Whether a listener serves HTTPS"},
+		ConfigOption{Name: "http.listener.*.sslcert", Description: "The certificate file of an SSL listener"},
+		ConfigOption{Name: "http.listener.*.sslkey", Description: "The private key file of an SSL listener"},
+		ConfigOption{Name: "http.listener.*.redirect", Description: "A URL, or the name of an SSL listener, to redirect all requests to"},
+	)
+}
+
+// serverListener is an address on which the server accepts connections.
+//
+// By default, there is a single listener, configured by http.addr, http.port
+// and http.ssl.  Several may be configured instead, by name, e.g. to serve
+// HTTP and HTTPS at the same time, plus a unix socket for a sidecar:
+//
+//      http.listeners = web, secure, sidecar
+//
+//      http.listener.web.addr = :80
+//      http.listener.web.redirect = secure        # redirect to the HTTPS listener
+//
+//      http.listener.secure.addr = :443
+//      http.listener.secure.ssl = true
+//      http.listener.secure.sslcert = /path/to/cert.pem
+//      http.listener.secure.sslkey = /path/to/key.pem
+//
+//      http.listener.sidecar.addr = unix:/tmp/app.sock
+//
+// All of the listeners share the Filters chain and the graceful shutdown.
+type serverListener struct {
+	Name     string // e.g. "secure", or "" for the http.addr / http.port listener
+	Network  string // e.g. "tcp", "unix"
+	Address  string // e.g. ":443", "/tmp/app.sock"
+	Ssl      bool
+	SslCert  string // e.g. "/path/to/cert.pem"
+	SslKey   string // e.g. "/path/to/key.pem"
+	Redirect string // e.g. "https://example.com", or the name of a listener
+
+	tls *tls.Config // Set by RunE for SSL listeners (see tlsConfig)
+}
+
+// The networks that may prefix a listener address, e.g. "unix:/tmp/app.sock".
+var listenerNetworks = []string{"tcp", "tcp4", "tcp6", "unix"}
+
+// configuredListeners returns the listeners configured in app.conf.  If port
+// is non-zero, it overrides http.port for the default listener.
+func configuredListeners(port int) ([]*serverListener, *Error) {
+	names := Config.StringDefault("http.listeners", "")
+	if names == "" {
+		l, err := defaultListener(port)
+		if err != nil {
+			return nil, err
+		}
+		return []*serverListener{l}, nil
+	}
+
+	var listeners []*serverListener
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		l, err := namedListener(name)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+
+	// Check that the redirects lead to HTTPS listeners.
+	for _, l := range listeners {
+		if l.Redirect == "" || strings.Contains(l.Redirect, "://") {
+			continue
+		}
+		target := findListener(listeners, l.Redirect)
+		if target == nil || !target.Ssl {
+			return nil, &Error{
+				Title:       "Invalid http.listener." + l.Name + ".redirect",
+				Description: "Expected a URL or the name of an SSL listener, got: " + l.Redirect,
+			}
+		}
+	}
+	return listeners, nil
+}
+
+// defaultListener returns the listener configured by http.addr, http.port and
+// http.ssl.
+func defaultListener(port int) (*serverListener, *Error) {
+	if port == 0 {
+		port = HttpPort
+	}
+	l := &serverListener{
+		Network: "tcp",
+		Ssl:     HttpSsl,
+		SslCert: HttpSslCert,
+		SslKey:  HttpSslKey,
+	}
+
+	// If the port is zero, treat the address as a fully qualified local address.
+	// This address must be prefixed with the network type followed by a colon,
+	// e.g. unix:/tmp/app.socket or tcp6:::1 (equivalent to tcp6:0:0:0:0:0:0:0:1)
+	if port == 0 {
+		parts := strings.SplitN(HttpAddr, ":", 2)
+		if len(parts) != 2 {
+			return nil, &Error{
+				Title:       "Invalid http.addr",
+				Description: "Expected network:address when no port is given, got: " + HttpAddr,
+			}
+		}
+		l.Network, l.Address = parts[0], parts[1]
+	} else {
+		l.Address = HttpAddr + ":" + strconv.Itoa(port)
+	}
+	return l, nil
+}
+
+// namedListener returns the listener configured by the http.listener.<name>.*
+// options.
+func namedListener(name string) (*serverListener, *Error) {
+	prefix := "http.listener." + name + "."
+	l := &serverListener{
+		Name:     name,
+		Network:  "tcp",
+		Address:  Config.StringDefault(prefix+"addr", ""),
+		Ssl:      Config.BoolDefault(prefix+"ssl", false),
+		SslCert:  Config.StringDefault(prefix+"sslcert", ""),
+		SslKey:   Config.StringDefault(prefix+"sslkey", ""),
+		Redirect: Config.StringDefault(prefix+"redirect", ""),
+	}
+	if l.Address == "" {
+		return nil, &Error{
+			Title:       "Invalid http.listener." + name,
+			Description: "No " + prefix + "addr provided.",
+		}
+	}
+	for _, network := range listenerNetworks {
+		if strings.HasPrefix(l.Address, network+":") {
+			l.Network, l.Address = network, l.Address[len(network)+1:]
+			break
+		}
+	}
+	if l.Ssl && (l.SslCert == "" || l.SslKey == "") {
+		return nil, &Error{
+			Title:       "Invalid http.listener." + name,
+			Description: "Both " + prefix + "sslcert and " + prefix + "sslkey must be provided for SSL.",
+		}
+	}
+	return l, nil
+}
+
+func findListener(listeners []*serverListener, name string) *serverListener {
+	for _, l := range listeners {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// newServer returns the http.Server serving on the listener: either the app,
+// or a redirect to HTTPS.
+func (l *serverListener) newServer(listeners []*serverListener) *http.Server {
+	var handler http.Handler = http.HandlerFunc(handle)
+	if l.Redirect != "" {
+		handler = l.redirectHandler(listeners)
+	}
+	return &http.Server{
+		Addr:              l.Address,
+		Handler:           handler,
+		TLSConfig:         l.tls,
+		ReadTimeout:       HttpReadTimeout,
+		WriteTimeout:      HttpWriteTimeout,
+		IdleTimeout:       HttpIdleTimeout,
+		ReadHeaderTimeout: HttpReadHeaderTimeout,
+		MaxHeaderBytes:    HttpMaxHeaderBytes,
+	}
+}
+
+// redirectHandler returns a handler redirecting every request permanently to
+// the same path on the redirect URL, or on the host of the request with the
+// port of the redirect listener.
+func (l *serverListener) redirectHandler(listeners []*serverListener) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		url := strings.TrimRight(l.Redirect, "/")
+		if !strings.Contains(url, "://") {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if target := findListener(listeners, l.Redirect); target != nil {
+				if _, port, err := net.SplitHostPort(target.Address); err == nil && port != "443" {
+					host = net.JoinHostPort(host, port)
+				}
+			}
+			url = "https://" + host
+		}
+		http.Redirect(w, r, url+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// serve accepts connections on the given net.Listener, over TLS if required.
+func (l *serverListener) serve(server *http.Server, listener net.Listener) error {
+	if l.Ssl {
+		// The certificate comes from server.TLSConfig.GetCertificate.
+		return server.ServeTLS(listener, "", "")
+	}
+	return server.Serve(listener)
+}
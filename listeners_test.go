@@ -0,0 +1,75 @@
+package revel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConfiguredListeners(t *testing.T) {
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+
+	Config = NewEmptyConfig()
+	Config.SetSection("prod")
+	Config.SetOption("http.listeners", "web, secure, sidecar")
+	Config.SetOption("http.listener.web.addr", ":80")
+	Config.SetOption("http.listener.web.redirect", "secure")
+	Config.SetOption("http.listener.secure.addr", ":8443")
+	Config.SetOption("http.listener.secure.ssl", "true")
+	Config.SetOption("http.listener.secure.sslcert", "cert.pem")
+	Config.SetOption("http.listener.secure.sslkey", "key.pem")
+	Config.SetOption("http.listener.sidecar.addr", "unix:/tmp/app.sock")
+	Config.SetOption("http.listener.sidecar.ssl", "true")
+	Config.SetOption("http.listener.sidecar.sslcert", "cert.pem")
+	Config.SetOption("http.listener.sidecar.sslkey", "key.pem")
+
+	listeners, err := configuredListeners(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []serverListener{
+		{Name: "web", Network: "tcp", Address: ":80", Redirect: "secure"},
+		{Name: "secure", Network: "tcp", Address: ":8443", Ssl: true, SslCert: "cert.pem", SslKey: "key.pem"},
+		{Name: "sidecar", Network: "unix", Address: "/tmp/app.sock", Ssl: true, SslCert: "cert.pem", SslKey: "key.pem"},
+	}
+	if len(listeners) != len(expected) {
+		t.Fatalf("Expected %d listeners, got %d", len(expected), len(listeners))
+	}
+	for i, l := range listeners {
+		if *l != expected[i] {
+			t.Errorf("Expected listener %+v, got %+v", expected[i], *l)
+		}
+	}
+
+	// Redirect to HTTPS, on the port of the secure listener.
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://example.com/hotels?page=2", nil)
+	listeners[0].newServer(listeners).Handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusMovedPermanently || resp.Header().Get("Location") != "https://example.com:8443/hotels?page=2" {
+		t.Errorf("Expected a redirect to https://example.com:8443/hotels?page=2, got %d %s",
+			resp.Code, resp.Header().Get("Location"))
+	}
+
+	// Redirects must lead to SSL listeners.
+	Config.SetOption("http.listener.web.redirect", "sidecar")
+	Config.SetOption("http.listener.sidecar.ssl", "false")
+	if _, err := configuredListeners(0); err == nil {
+		t.Error("Expected an error for a redirect to a listener without SSL")
+	}
+}
+
+func TestDefaultListener(t *testing.T) {
+	oldAddr, oldPort := HttpAddr, HttpPort
+	defer func() { HttpAddr, HttpPort = oldAddr, oldPort }()
+
+	HttpAddr, HttpPort = "127.0.0.1", 9000
+	if l, err := defaultListener(9001); err != nil || l.Network != "tcp" || l.Address != "127.0.0.1:9001" {
+		t.Errorf("Expected tcp 127.0.0.1:9001, got %+v (%v)", l, err)
+	}
+
+	HttpAddr, HttpPort = "unix:/tmp/app.sock", 0
+	if l, err := defaultListener(0); err != nil || l.Network != "unix" || l.Address != "/tmp/app.sock" {
+		t.Errorf("Expected unix /tmp/app.sock, got %+v (%v)", l, err)
+	}
+}
@@ -0,0 +1,272 @@
+package revel
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// AppLog is the structured logger of Revel and the application.  Each record
+// goes to the output of its level (log.trace.output for debug records,
+// log.info.output, log.warn.output or log.error.output), formatted as
+// key=value pairs or, with "log.format = json", as JSON lines:
+//
+//      revel.AppLog.Info("Booking confirmed", "hotel", hotel.Id, "nights", booking.Nights())
+//
+// The minimum level is given by log.level (trace, info, warn or error; trace
+// by default), or by log.level.<module> for the records of a module logger
+// (see ModuleLog).  Within a request, use Controller.Log, which carries the
+// request ID, action and client IP.
+//
+// AppLog and the loggers derived from it may be kept from package
+// initialization: they follow the configuration loaded by Init.
+//
+// The TRACE, INFO, WARN and ERROR loggers remain.  With the JSON format, their
+// lines become records of AppLog too.
+var AppLog = slog.New(&logHandler{})
+
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "log.*.output", Default: "stderr", Description: `"stdout", "stderr", "off" or a file name`},
+		ConfigOption{Name: "log.*.prefix", Description: "The prefix of the log lines"},
+		ConfigOption{Name: "log.*.flags", Type: ConfigInt, Description: "The flags of the logger, as defined by the log package"},
+		ConfigOption{Name: "log.format", Default: "text", Description: "The format of AppLog: text (key=value pairs) or json"},
+		ConfigOption{Name: "log.level", Default: "trace", Description: "The minimum level of AppLog: trace, info, warn or error"},
+		ConfigOption{Name: "log.level.*", Description: "The minimum level of the records of a module logger (see ModuleLog)"},
+	)
+
+	currentLogOutputs.Store(newLogOutputs(map[string]io.Writer{"error": os.Stderr}, "text"))
+}
+
+// ModuleLog returns a logger for a module, e.g. "cache", whose minimum level
+// may be set with log.level.<module>.  It is AppLog.With("module", module).
+func ModuleLog(module string) *slog.Logger {
+	return AppLog.With("module", module)
+}
+
+// RequestLog returns a logger for a request, with its ID and client IP.  It is
+// the Controller.Log of the requests served, to which the action is added.
+func RequestLog(req *Request) *slog.Logger {
+	return AppLog.With("request_id", req.ID, "client_ip", req.ClientIP())
+}
+
+// The log levels, by name, and the legacy logger of each.
+var (
+	logLevelNames = []string{"trace", "info", "warn", "error"}
+	logLevels     = map[string]slog.Level{
+		"trace": slog.LevelDebug,
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+)
+
+// The minimum levels: "" for log.level, and each module of log.level.<module>.
+var (
+	minLogLevels      = map[string]slog.Level{"": slog.LevelDebug}
+	minLogLevelsMutex sync.RWMutex
+)
+
+func minLogLevel(module string) slog.Level {
+	minLogLevelsMutex.RLock()
+	defer minLogLevelsMutex.RUnlock()
+	if level, ok := minLogLevels[module]; ok {
+		return level
+	}
+	return minLogLevels[""]
+}
+
+// logOutputs holds the handlers writing to the output of each level, by index
+// of logLevelNames (nil if the output is off).  They are replaced when Init
+// configures the logging.
+type logOutputs struct {
+	handlers [4]slog.Handler
+}
+
+var currentLogOutputs atomic.Pointer[logOutputs]
+
+func newLogOutputs(outputs map[string]io.Writer, format string) *logOutputs {
+	o := &logOutputs{}
+	for i, name := range logLevelNames {
+		w := outputs[name]
+		if w == nil || w == ioutil.Discard {
+			continue
+		}
+		opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+		if format == "json" {
+			o.handlers[i] = slog.NewJSONHandler(w, opts)
+		} else {
+			o.handlers[i] = slog.NewTextHandler(w, opts)
+		}
+	}
+	return o
+}
+
+// logHandler is the slog.Handler of AppLog, dispatching the records to the
+// output of their level, if enabled for their module.  The loggers derived
+// with With or WithGroup follow the outputs configured later, so that they
+// may be kept from package initialization.
+type logHandler struct {
+	module string
+	derive []func(slog.Handler) slog.Handler // The WithAttrs and WithGroup calls
+
+	derived atomic.Pointer[derivedLogOutputs]
+}
+
+// derivedLogOutputs caches the handlers derived from the current outputs.
+type derivedLogOutputs struct {
+	outputs  *logOutputs
+	handlers [4]slog.Handler
+}
+
+func (h *logHandler) handler(level slog.Level) slog.Handler {
+	outputs := currentLogOutputs.Load()
+	if outputs == nil {
+		return nil
+	}
+	derived := h.derived.Load()
+	if derived == nil || derived.outputs != outputs {
+		derived = &derivedLogOutputs{outputs: outputs}
+		for i, handler := range outputs.handlers {
+			if handler == nil {
+				continue
+			}
+			for _, f := range h.derive {
+				handler = f(handler)
+			}
+			derived.handlers[i] = handler
+		}
+		h.derived.Store(derived)
+	}
+
+	switch {
+	case level >= slog.LevelError:
+		return derived.handlers[3]
+	case level >= slog.LevelWarn:
+		return derived.handlers[2]
+	case level >= slog.LevelInfo:
+		return derived.handlers[1]
+	}
+	return derived.handlers[0]
+}
+
+func (h *logHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= minLogLevel(h.module) && h.handler(level) != nil
+}
+
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	if handler := h.handler(r.Level); handler != nil {
+		return handler.Handle(ctx, r)
+	}
+	return nil
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	child := h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+	for _, attr := range attrs {
+		if attr.Key == "module" {
+			child.module = attr.Value.String()
+		}
+	}
+	return child
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *logHandler) with(f func(slog.Handler) slog.Handler) *logHandler {
+	derive := make([]func(slog.Handler) slog.Handler, len(h.derive), len(h.derive)+1)
+	copy(derive, h.derive)
+	return &logHandler{module: h.module, derive: append(derive, f)}
+}
+
+// logWriter turns the lines written by a legacy logger into records of AppLog.
+type logWriter struct {
+	level slog.Level
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	AppLog.Log(context.Background(), w.level, strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// configureLogging sets up AppLog and the legacy loggers from the log.*
+// options.
+func configureLogging() *Error {
+	format := Config.StringDefault("log.format", "text")
+	if format != "text" && format != "json" {
+		return &Error{
+			Title:       "app.conf: Invalid log.format",
+			Description: "Expected text or json, got: " + format,
+		}
+	}
+
+	levels := make(map[string]slog.Level)
+	for _, option := range append(Config.Options("log.level."), "log.level") {
+		name := Config.StringDefault(option, "trace")
+		level, ok := logLevels[strings.ToLower(name)]
+		if !ok {
+			return &Error{
+				Title:       "app.conf: Invalid " + option,
+				Description: "Expected trace, info, warn or error, got: " + name,
+			}
+		}
+		levels[strings.TrimPrefix(strings.TrimPrefix(option, "log.level"), ".")] = level
+	}
+
+	// The log files no longer used once configured are closed.
+	releaseLogFiles()
+	outputs := make(map[string]io.Writer)
+	loggers := make(map[string]*log.Logger)
+	for _, name := range logLevelNames {
+		output, err := logOutput(name, "stderr")
+		if err != nil {
+			return err
+		}
+		outputs[name] = output
+		if format == "json" {
+			loggers[name] = log.New(logWriter{logLevels[name]}, "", 0)
+		} else {
+			loggers[name] = getLogger(name, output)
+		}
+	}
+
+	minLogLevelsMutex.Lock()
+	minLogLevels = levels
+	minLogLevelsMutex.Unlock()
+	currentLogOutputs.Store(newLogOutputs(outputs, format))
+	TRACE, INFO, WARN, ERROR = loggers["trace"], loggers["info"], loggers["warn"], loggers["error"]
+	if err := configureAccessLog(); err != nil {
+		return err
+	}
+	closeUnusedLogFiles()
+	return nil
+}
+
+// logOutput returns the writer of log.<name>.output: stdout, stderr, off, or the
+// path of a file (see logFile for its rotation).
+func logOutput(name, dfault string) (io.Writer, *Error) {
+	output := Config.StringDefault("log."+name+".output", dfault)
+	switch output {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	case "off":
+		return ioutil.Discard, nil
+	}
+
+	file, err := openLogFile(name, output)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
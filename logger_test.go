@@ -0,0 +1,100 @@
+package revel
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStructuredLogging(t *testing.T) {
+	oldConfig, oldOutputs := Config, currentLogOutputs.Load()
+	oldTrace, oldInfo, oldWarn, oldError := TRACE, INFO, WARN, ERROR
+	defer func() {
+		Config = oldConfig
+		currentLogOutputs.Store(oldOutputs)
+		minLogLevelsMutex.Lock()
+		minLogLevels = map[string]slog.Level{"": slog.LevelDebug}
+		minLogLevelsMutex.Unlock()
+		TRACE, INFO, WARN, ERROR = oldTrace, oldInfo, oldWarn, oldError
+		closeTestLogFiles()
+	}()
+
+	// A module logger kept from before the configuration.
+	cacheLog := ModuleLog("cache")
+
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	Config = NewEmptyConfig()
+	Config.SetSection("prod")
+	Config.SetOption("log.format", "json")
+	Config.SetOption("log.level", "info")
+	Config.SetOption("log.level.cache", "warn")
+	Config.SetOption("log.trace.output", logFile)
+	Config.SetOption("log.info.output", logFile)
+	Config.SetOption("log.warn.output", logFile)
+	Config.SetOption("log.error.output", "off")
+	if err := configureLogging(); err != nil {
+		t.Fatal(err)
+	}
+
+	AppLog.Debug("filtered out by log.level")
+	AppLog.Info("Booking confirmed", "hotel", 3)
+	cacheLog.Info("filtered out by log.level.cache")
+	cacheLog.Warn("Cache unreachable")
+	INFO.Println("Legacy line")
+	ERROR.Println("Discarded")
+
+	req, _ := http.NewRequest("GET", "/hotels/3", nil)
+	req.Header.Set("X-Request-Id", "abc123")
+	req.RemoteAddr = "10.0.0.1:1234"
+	c := NewController(NewRequest(req), NewResponse(nil))
+	c.Log = RequestLog(c.Request)
+	c.Action = "Hotels.Show"
+	c.Log = c.Log.With("action", c.Action)
+	c.Log.Warn("Hotel not found")
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	expected := []map[string]interface{}{
+		{"level": "INFO", "msg": "Booking confirmed", "hotel": float64(3)},
+		{"level": "WARN", "msg": "Cache unreachable", "module": "cache"},
+		{"level": "INFO", "msg": "Legacy line"},
+		{"level": "WARN", "msg": "Hotel not found", "request_id": "abc123", "client_ip": "10.0.0.1", "action": "Hotels.Show"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got:\n%s", len(expected), content)
+	}
+	for i, line := range lines {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Expected a JSON line, got %s", line)
+		}
+		for key, value := range expected[i] {
+			if record[key] != value {
+				t.Errorf("Expected %s = %v in %s", key, value, line)
+			}
+		}
+	}
+
+	Config.SetOption("log.level.db", "verbose")
+	if err := configureLogging(); err == nil {
+		t.Error("Expected an invalid level to be reported")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	if id := NewRequest(req).ID; len(id) != 16 {
+		t.Errorf("Expected a random ID, got %q", id)
+	}
+	req.Header.Set("X-Request-Id", strings.Repeat("x", 200))
+	if id := NewRequest(req).ID; len(id) != 16 {
+		t.Errorf("Expected an unreasonable X-Request-Id to be replaced, got %q", id)
+	}
+}
@@ -0,0 +1,331 @@
+package revel
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "log.*.rotate.size", Description: "The size beyond which to rotate the log file, e.g. 100MB"},
+		ConfigOption{Name: "log.*.rotate.interval", Type: ConfigDuration, Description: "How often to rotate the log file, e.g. 24h"},
+		ConfigOption{Name: "log.*.rotate.keep", Type: ConfigInt, Default: "0", Description: "How many rotated log files to keep, or 0 for all"},
+		ConfigOption{Name: "log.*.rotate.compress", Type: ConfigBool, Default: "false", Description: "Whether to gzip the rotated log files"},
+	)
+}
+
+// logFile is a log file, rotated by size and/or time as configured by the
+// log.<name>.rotate.* options of the first logger opening it:
+//
+//      log.info.output = app.log
+//      log.info.rotate.size = 100MB      # rotate when the file would exceed 100MB
+//      log.info.rotate.interval = 24h    # rotate every day (at midnight UTC)
+//      log.info.rotate.keep = 7          # keep the 7 latest rotated files (0 for all)
+//      log.info.rotate.compress = true   # gzip the rotated files
+//
+// A rotated file is renamed after the time of its rotation, e.g.
+// app.log.2006-01-02T15-04-05.000.gz, followed by a sequence number if
+// another file was rotated within the same millisecond, e.g.
+// app.log.2006-01-02T15-04-05.000.1.gz.  Every log file is reopened on SIGHUP,
+// so that an external logrotate may move it away instead.
+type logFile struct {
+	path     string
+	size     int64         // The maximum size of the file, or 0
+	interval time.Duration // The rotation interval, or 0
+	keep     int           // How many rotated files to keep, or 0 for all
+	compress bool
+
+	mutex   sync.Mutex
+	file    *os.File
+	written int64     // The size of the file
+	period  time.Time // The start of the interval of the file
+
+	pending sync.WaitGroup // The compressions and prunings in progress
+	pruning sync.Mutex     // Serializes them
+
+	used bool // Set if opened by a logger since releaseLogFiles
+}
+
+var (
+	logFiles      = make(map[string]*logFile) // By absolute path
+	logFilesMutex sync.Mutex
+	logFilesHup   sync.Once
+)
+
+// openLogFile returns the log file at the path, opening it with the rotation
+// options of log.<name>.rotate.* unless it is already open.
+func openLogFile(name, path string) (*logFile, *Error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	logFilesMutex.Lock()
+	defer logFilesMutex.Unlock()
+	if f, ok := logFiles[absPath]; ok {
+		f.used = true
+		return f, nil
+	}
+
+	prefix := "log." + name + ".rotate."
+	f := &logFile{
+		path:     path,
+		keep:     Config.IntDefault(prefix+"keep", 0),
+		compress: Config.BoolDefault(prefix+"compress", false),
+		used:     true,
+	}
+	if size, found := Config.String(prefix + "size"); found {
+		if f.size, err = parseByteSize(size); err != nil {
+			return nil, &Error{
+				Title:       "app.conf: Invalid " + prefix + "size",
+				Description: "Expected a size (e.g. 100MB), got: " + size,
+			}
+		}
+	}
+	var derr *Error
+	if f.interval, derr = durationOption(prefix+"interval", 0); derr != nil {
+		return nil, derr
+	}
+	if err := f.open(); err != nil {
+		return nil, &Error{
+			Title:       "Failed to open log file",
+			Path:        path,
+			Description: err.Error(),
+		}
+	}
+	logFiles[absPath] = f
+
+	logFilesHup.Do(func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				reopenLogFiles()
+			}
+		}()
+	})
+	return f, nil
+}
+
+// releaseLogFiles marks every log file as unused, before the loggers are
+// configured anew.  The files they do not open again are then closed by
+// closeUnusedLogFiles.
+func releaseLogFiles() {
+	logFilesMutex.Lock()
+	defer logFilesMutex.Unlock()
+	for _, f := range logFiles {
+		f.used = false
+	}
+}
+
+// closeUnusedLogFiles closes and forgets the log files unused since
+// releaseLogFiles.
+func closeUnusedLogFiles() {
+	logFilesMutex.Lock()
+	defer logFilesMutex.Unlock()
+	for absPath, f := range logFiles {
+		if f.used {
+			continue
+		}
+		f.pending.Wait()
+		f.mutex.Lock()
+		f.file.Close()
+		f.mutex.Unlock()
+		delete(logFiles, absPath)
+	}
+}
+
+// reopenLogFiles reopens every log file, e.g. once logrotate has moved them.
+func reopenLogFiles() {
+	logFilesMutex.Lock()
+	defer logFilesMutex.Unlock()
+	for _, f := range logFiles {
+		f.mutex.Lock()
+		if err := f.open(); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to reopen log file", f.path, ":", err)
+		}
+		f.mutex.Unlock()
+	}
+}
+
+// open opens the file at the path, and replaces the current one with it.  If
+// it fails, the current file is kept, so that the logs are not lost.
+func (f *logFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file, f.written, f.period = file, 0, time.Now()
+	if fileInfo, err := file.Stat(); err == nil {
+		f.written = fileInfo.Size()
+		f.period = fileInfo.ModTime()
+	}
+	if f.interval > 0 {
+		f.period = f.period.Truncate(f.interval)
+	}
+	return nil
+}
+
+func (f *logFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.dueForRotation(len(p)) {
+		if err := f.rotate(); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to rotate log file", f.path, ":", err)
+			// Retry after another size or interval, rather than at every write.
+			f.written = 0
+			if f.interval > 0 {
+				f.period = time.Now().Truncate(f.interval)
+			}
+		}
+	}
+	n, err := f.file.Write(p)
+	f.written += int64(n)
+	return n, err
+}
+
+func (f *logFile) dueForRotation(size int) bool {
+	if f.size > 0 && f.written > 0 && f.written+int64(size) > f.size {
+		return true
+	}
+	return f.interval > 0 && time.Now().Truncate(f.interval).After(f.period)
+}
+
+// The layout of the time in the names of the rotated files.
+const rotatedTimeLayout = "2006-01-02T15-04-05.000"
+
+// rotate renames the file after the current time and opens a new one, then
+// compresses and prunes the rotated files in the background.
+func (f *logFile) rotate() error {
+	rotated := f.path + "." + time.Now().Format(rotatedTimeLayout)
+	for i := 1; logFileExists(rotated) || logFileExists(rotated+".gz"); i++ {
+		rotated = f.path + "." + time.Now().Format(rotatedTimeLayout) + "." + strconv.Itoa(i)
+	}
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		// Keep writing to the current file, under its name.
+		os.Rename(rotated, f.path)
+		return err
+	}
+
+	f.pending.Add(1)
+	go func() {
+		defer f.pending.Done()
+		f.pruning.Lock()
+		defer f.pruning.Unlock()
+		if f.compress {
+			if err := gzipFile(rotated); err != nil {
+				fmt.Fprintln(os.Stderr, "Failed to compress log file", rotated, ":", err)
+			}
+		}
+		f.prune()
+	}()
+	return nil
+}
+
+// prune removes the oldest rotated files beyond the number to keep.
+func (f *logFile) prune() {
+	if f.keep <= 0 {
+		return
+	}
+	matches, _ := filepath.Glob(f.path + ".*")
+	type rotatedFile struct {
+		name, time string
+		seq        int
+	}
+	var rotated []rotatedFile
+	for _, match := range matches {
+		// Only the files named by rotate, e.g. app.log.2006-01-02T15-04-05.000[.1][.gz]
+		suffix := strings.TrimSuffix(match[len(f.path)+1:], ".gz")
+		if len(suffix) < len(rotatedTimeLayout) {
+			continue
+		}
+		file := rotatedFile{name: match, time: suffix[:len(rotatedTimeLayout)]}
+		if _, err := time.Parse(rotatedTimeLayout, file.time); err != nil {
+			continue
+		}
+		if seq := suffix[len(rotatedTimeLayout):]; seq != "" {
+			var err error
+			if file.seq, err = strconv.Atoi(strings.TrimPrefix(seq, ".")); err != nil || seq[0] != '.' {
+				continue
+			}
+		}
+		rotated = append(rotated, file)
+	}
+	sort.Slice(rotated, func(i, j int) bool {
+		if rotated[i].time != rotated[j].time {
+			return rotated[i].time < rotated[j].time
+		}
+		return rotated[i].seq < rotated[j].seq
+	})
+	for i := 0; i < len(rotated)-f.keep; i++ {
+		os.Remove(rotated[i].name)
+	}
+}
+
+// logFileExists returns true if there is a file with the given name.
+func logFileExists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// gzipFile compresses the file into name.gz, then removes it.
+func gzipFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
+
+// parseByteSize parses a size in bytes, with an optional unit: KB, MB or GB
+// (powers of 1024).
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, multiplier = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,210 @@
+package revel
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// closeTestLogFiles closes and forgets the log files opened by a test.
+func closeTestLogFiles() {
+	releaseLogFiles()
+	closeUnusedLogFiles()
+}
+
+func TestLogFileRotation(t *testing.T) {
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+	defer closeTestLogFiles()
+	Config = NewEmptyConfig()
+	Config.SetSection("prod")
+	Config.SetOption("log.test.rotate.size", "1KB")
+	Config.SetOption("log.test.rotate.keep", "2")
+	Config.SetOption("log.test.rotate.compress", "true")
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := openLogFile("test", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same, _ := openLogFile("other", path); same != f {
+		t.Error("Expected the loggers of a file to share it")
+	}
+
+	line := strings.Repeat("x", 99) + "\n"
+	for i := 0; i < 40; i++ {
+		f.Write([]byte(line))
+		time.Sleep(time.Millisecond) // Name the rotated files apart
+	}
+	f.pending.Wait()
+
+	// 40 lines of 100 bytes: 3 rotations of 10 lines, the 2 latest kept.
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 2 {
+		t.Fatalf("Expected 2 rotated files, got %v", rotated)
+	}
+	for _, name := range rotated {
+		if !strings.HasSuffix(name, ".gz") {
+			t.Errorf("Expected %s to be compressed", name)
+			continue
+		}
+		file, _ := os.Open(name)
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(gz)
+		file.Close()
+		if len(content) != 10*len(line) {
+			t.Errorf("Expected 10 lines in %s, got %d bytes", name, len(content))
+		}
+	}
+	if fileInfo, _ := os.Stat(path); fileInfo.Size() != 10*int64(len(line)) {
+		t.Errorf("Expected 10 lines in the current file, got %d bytes", fileInfo.Size())
+	}
+
+	// Reopen after an external rotation.
+	os.Rename(path, path+".moved")
+	reopenLogFiles()
+	f.Write([]byte(line))
+	if fileInfo, err := os.Stat(path); err != nil || fileInfo.Size() != int64(len(line)) {
+		t.Errorf("Expected the log file to be reopened, got %v", err)
+	}
+}
+
+func TestLogFileRotationBurst(t *testing.T) {
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+	defer closeTestLogFiles()
+	Config = NewEmptyConfig()
+	Config.SetSection("prod")
+	Config.SetOption("log.test.rotate.size", "100")
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := openLogFile("test", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotations within the same millisecond do not overwrite each other.
+	line := strings.Repeat("x", 99) + "\n"
+	for i := 0; i < 6; i++ {
+		f.Write([]byte(line))
+	}
+	f.pending.Wait()
+	if rotated, _ := filepath.Glob(path + ".*"); len(rotated) != 5 {
+		t.Errorf("Expected 5 rotated files, got %v", rotated)
+	}
+
+	// The sequence numbers follow the time in the pruning order.
+	dir := t.TempDir()
+	f = &logFile{path: filepath.Join(dir, "app.log"), keep: 2}
+	for _, name := range []string{"2006-01-02T15-04-05.999.gz", "2006-01-02T15-04-06.000.gz",
+		"2006-01-02T15-04-06.000.2.gz", "2006-01-02T15-04-06.000.10.gz", "2006-01-02T15-04-06.000.x"} {
+		os.WriteFile(f.path+"."+name, nil, 0666)
+	}
+	f.prune()
+	remaining, _ := filepath.Glob(f.path + ".*")
+	expected := []string{f.path + ".2006-01-02T15-04-06.000.10.gz", f.path + ".2006-01-02T15-04-06.000.2.gz",
+		f.path + ".2006-01-02T15-04-06.000.x"}
+	if strings.Join(remaining, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected %v to remain, got %v", expected, remaining)
+	}
+}
+
+func TestLogFileIntervalRotation(t *testing.T) {
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+	defer closeTestLogFiles()
+	Config = NewEmptyConfig()
+	Config.SetSection("prod")
+	Config.SetOption("log.test.rotate.interval", "1h")
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := openLogFile("test", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("first\n"))
+	f.period = f.period.Add(-time.Hour) // As if written an hour ago
+	f.Write([]byte("second\n"))
+	f.pending.Wait()
+
+	if rotated, _ := filepath.Glob(path + ".*"); len(rotated) != 1 {
+		t.Errorf("Expected 1 rotated file, got %v", rotated)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "second\n" {
+		t.Errorf("Expected the current file to start anew, got %q", content)
+	}
+}
+
+func TestLogFileReopenFailure(t *testing.T) {
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+	defer closeTestLogFiles()
+	Config = NewEmptyConfig()
+	Config.SetSection("prod")
+
+	dir := filepath.Join(t.TempDir(), "logs")
+	os.Mkdir(dir, 0777)
+	f, err := openLogFile("test", filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The directory is gone: the current file is kept.
+	os.RemoveAll(dir)
+	reopenLogFiles()
+	if _, err := f.Write([]byte("kept\n")); err != nil {
+		t.Errorf("Expected the current file to be kept, got %s", err)
+	}
+	if err := f.rotate(); err == nil {
+		t.Error("Expected the rotation to fail")
+	}
+	if _, err := f.Write([]byte("kept\n")); err != nil {
+		t.Errorf("Expected the current file to be kept, got %s", err)
+	}
+}
+
+func TestCloseUnusedLogFiles(t *testing.T) {
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+	defer closeTestLogFiles()
+	Config = NewEmptyConfig()
+	Config.SetSection("prod")
+
+	dir := t.TempDir()
+	first, _ := openLogFile("test", filepath.Join(dir, "first.log"))
+	releaseLogFiles()
+	second, _ := openLogFile("test", filepath.Join(dir, "second.log"))
+	closeUnusedLogFiles()
+
+	logFilesMutex.Lock()
+	defer logFilesMutex.Unlock()
+	for _, f := range logFiles {
+		if f == first {
+			t.Error("Expected the unused log file to be forgotten")
+		}
+	}
+	if logFiles[second.path] != second {
+		t.Error("Expected the used log file to be kept")
+	}
+	if _, err := first.file.Write([]byte("closed\n")); err == nil {
+		t.Error("Expected the unused log file to be closed")
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for s, expected := range map[string]int64{"512": 512, "10KB": 10 << 10, "100MB": 100 << 20, "1g": 1 << 30, "2 MB": 2 << 20} {
+		if size, err := parseByteSize(s); err != nil || size != expected {
+			t.Errorf("Expected %s to be %d bytes, got %d (%v)", s, expected, size, err)
+		}
+	}
+	if _, err := parseByteSize("lots"); err == nil {
+		t.Error("Expected an invalid size to fail")
+	}
+}
@@ -0,0 +1,376 @@
+package revel
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "metrics.path", Default: "/metrics", Description: "The path at which MetricsFilter serves the metrics, or empty to not serve them"},
+	)
+}
+
+// The default buckets of the duration histograms, in seconds.
+var DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// The default buckets of the size histograms, in bytes.
+var SizeBuckets = []float64{100, 1000, 10000, 100000, 1e6, 1e7}
+
+// metric is a family of time series sharing a name, e.g. the request counts
+// of every action.
+type metric struct {
+	name, help, kind string
+	labels           []string
+	buckets          []float64 // Histograms only
+
+	mutex  sync.Mutex
+	series map[string]*metricSeries // By label values, joined with "\xff"
+}
+
+type metricSeries struct {
+	labelValues []string
+	value       float64  // The value of a counter or gauge, or the sum of a histogram
+	counts      []uint64 // The observations per bucket, not cumulative
+	count       uint64
+}
+
+var (
+	metrics      = make(map[string]*metric)
+	metricsMutex sync.Mutex
+)
+
+// newMetric registers a metric, or returns the one registered with the same
+// name and kind.
+func newMetric(name, help, kind string, labels []string, buckets []float64) *metric {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+	if m, ok := metrics[name]; ok {
+		if m.kind != kind || len(m.labels) != len(labels) {
+			panic(fmt.Sprintf("revel: metric %s is already registered as a %s of %d labels", name, m.kind, len(m.labels)))
+		}
+		return m
+	}
+	m := &metric{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*metricSeries),
+	}
+	metrics[name] = m
+	return m
+}
+
+// update calls f with the series of the label values, under the lock.
+func (m *metric) update(labelValues []string, f func(s *metricSeries)) {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("revel: metric %s expects %d label values, got %d", m.name, len(m.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	s, ok := m.series[key]
+	if !ok {
+		s = &metricSeries{labelValues: append([]string(nil), labelValues...)}
+		if m.buckets != nil {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	f(s)
+}
+
+// Counter is a metric that only goes up, e.g. a number of requests.
+type Counter struct{ m *metric }
+
+// NewCounter registers a counter with the given label names, or returns the
+// one registered under the same name.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{newMetric(name, help, "counter", labels, nil)}
+}
+
+// Inc adds one to the series of the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds a positive value to the series of the given label values.
+func (c *Counter) Add(value float64, labelValues ...string) {
+	c.m.update(labelValues, func(s *metricSeries) { s.value += value })
+}
+
+// Gauge is a metric that goes up and down, e.g. a number of requests in flight.
+type Gauge struct{ m *metric }
+
+// NewGauge registers a gauge with the given label names, or returns the one
+// registered under the same name.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{newMetric(name, help, "gauge", labels, nil)}
+}
+
+// Set sets the series of the given label values.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.m.update(labelValues, func(s *metricSeries) { s.value = value })
+}
+
+// Add adds a value, possibly negative, to the series of the given label values.
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	g.m.update(labelValues, func(s *metricSeries) { s.value += value })
+}
+
+// Histogram is a metric counting observations in buckets, e.g. the durations
+// of requests.
+type Histogram struct{ m *metric }
+
+// NewHistogram registers a histogram with the given upper bounds of the
+// buckets, in increasing order, and label names, or returns the one registered
+// under the same name.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{newMetric(name, help, "histogram", labels, buckets)}
+}
+
+// Observe records a value in the series of the given label values.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.m.update(labelValues, func(s *metricSeries) {
+		if i := sort.SearchFloat64s(h.m.buckets, value); i < len(s.counts) {
+			s.counts[i]++
+		}
+		s.value += value
+		s.count++
+	})
+}
+
+// WriteMetrics writes every registered metric in the Prometheus text format.
+func WriteMetrics(w io.Writer) error {
+	metricsMutex.Lock()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	metricsMutex.Unlock()
+	sort.Strings(names)
+
+	out := bufio.NewWriter(w)
+	for _, name := range names {
+		metricsMutex.Lock()
+		m := metrics[name]
+		metricsMutex.Unlock()
+		m.write(out)
+	}
+	return out.Flush()
+}
+
+func (m *metric) write(w *bufio.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := m.series[key]
+		if m.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, m.formatLabels(s.labelValues, ""), formatMetricValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, m.formatLabels(s.labelValues, formatMetricValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, m.formatLabels(s.labelValues, "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, m.formatLabels(s.labelValues, ""), formatMetricValue(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, m.formatLabels(s.labelValues, ""), s.count)
+	}
+}
+
+// formatLabels returns e.g. {action="Hotels.Show",le="0.5"}, with the "le"
+// label of a histogram bucket if given.
+func (m *metric) formatLabels(labelValues []string, le string) string {
+	var pairs []string
+	for i, label := range m.labels {
+		pairs = append(pairs, label+`="`+metricLabelEscaper.Replace(labelValues[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatMetricValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	requestsTotal = NewCounter("revel_http_requests_total",
+		"The number of requests served.", "action", "method", "status")
+	requestDuration = NewHistogram("revel_http_request_duration_seconds",
+		"The duration of the requests, up to the response being written.", DurationBuckets, "action", "method", "status")
+	responseSize = NewHistogram("revel_http_response_size_bytes",
+		"The size of the response bodies, before compression.", SizeBuckets, "action", "method", "status")
+	requestsInFlight = NewGauge("revel_http_requests_in_flight",
+		"The number of requests being served.")
+	templateRenderDuration = NewHistogram("revel_template_render_duration_seconds",
+		"The duration of the template renderings.", DurationBuckets, "template")
+)
+
+// MetricsFilter records the number, duration and response size of the
+// requests, by action ("none" if unrouted), method and status, and the
+// number of requests in flight.  It serves every registered metric, in the
+// Prometheus text format, at metrics.path ("/metrics" by default, or the empty
+// string to only record them).
+//
+// It is not one of the default Filters, as the metrics should usually not be
+// public.  Add it before the RouterFilter to measure every request:
+//
+//      revel.Filters = []revel.Filter{
+//          revel.PanicFilter,
+//          revel.MetricsFilter,
+//          revel.RouterFilter,
+//          ...
+//      }
+//
+// Modules and apps may register metrics of their own with NewCounter, NewGauge
+// and NewHistogram.
+var MetricsFilter = func(c *Controller, fc []Filter) {
+	if path := Config.StringDefault("metrics.path", "/metrics"); path != "" && c.Request.URL.Path == path {
+		var b bytes.Buffer
+		WriteMetrics(&b)
+		c.Response.ContentType = "text/plain; version=0.0.4; charset=utf-8"
+		c.Result = c.RenderText("%s", b.String())
+		return
+	}
+
+	start := time.Now()
+	requestsInFlight.Add(1)
+	applying := false // Then metricsResult.Apply takes the request out of flight.
+	defer func() {
+		if !applying {
+			requestsInFlight.Add(-1)
+		}
+	}()
+	defer recordRequestOnPanic(c, start, nil)
+
+	fc[0](c, fc[1:])
+	if c.Result == nil {
+		recordRequest(c, start, 0)
+		return
+	}
+	c.Result = &metricsResult{c.Result, c, start}
+	applying = true
+}
+
+// metricsResult applies a result, then records the request once the response
+// is written.
+type metricsResult struct {
+	Result
+	c     *Controller
+	start time.Time
+}
+
+func (r *metricsResult) Apply(req *Request, resp *Response) {
+	defer requestsInFlight.Add(-1)
+	out := &countingResponseWriter{ResponseWriter: resp.Out}
+	resp.Out = out
+	defer recordRequestOnPanic(r.c, r.start, out)
+	r.Result.Apply(req, resp)
+	resp.Out = out.ResponseWriter
+	recordRequest(r.c, r.start, out.written)
+}
+
+// The methods recorded as is.  The others, sent by any client, are recorded
+// as OTHER, so as not to create a series per method.
+var metricsMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE", "WS"}
+
+// recordRequestOnPanic records the request if it is panicking, with a 500
+// status unless another one was set, then goes on panicking.  It must be
+// deferred.
+func recordRequestOnPanic(c *Controller, start time.Time, out *countingResponseWriter) {
+	if err := recover(); err != nil {
+		if c.Response.Status == 0 {
+			c.Response.Status = http.StatusInternalServerError
+		}
+		var size int64
+		if out != nil {
+			size = out.written
+		}
+		recordRequest(c, start, size)
+		panic(err)
+	}
+}
+
+// recordRequest records a request once its response is written.
+func recordRequest(c *Controller, start time.Time, size int64) {
+	action := c.Action
+	if action == "" {
+		action = "none"
+	}
+	status := c.Response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	method := c.Request.Method
+	if !ContainsString(metricsMethods, method) {
+		method = "OTHER"
+	}
+	labels := []string{action, method, strconv.Itoa(status)}
+	requestsTotal.Inc(labels...)
+	requestDuration.Observe(time.Since(start).Seconds(), labels...)
+	responseSize.Observe(float64(size), labels...)
+}
+
+// countingResponseWriter counts the bytes written to the body of a response.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	written int64
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush sends the buffered data to the client, if the wrapped writer can.
+func (w *countingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// CloseNotify returns the channel of the wrapped writer, or nil (never ready)
+// if it has none.
+func (w *countingResponseWriter) CloseNotify() <-chan bool {
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return nil
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
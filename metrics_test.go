@@ -0,0 +1,148 @@
+package revel
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// resetMetrics clears the series of the given metrics, so that the tests may
+// run repeatedly.
+func resetMetrics(names ...string) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+	for _, name := range names {
+		if m, ok := metrics[name]; ok {
+			m.mutex.Lock()
+			m.series = make(map[string]*metricSeries)
+			m.mutex.Unlock()
+		}
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	resetMetrics("test_requests_total", "test_in_flight", "test_duration_seconds")
+	counter := NewCounter("test_requests_total", "The number of \"test\" requests.", "path")
+	counter.Inc(`/a"b`)
+	counter.Add(2, "/c")
+	gauge := NewGauge("test_in_flight", "The number of test requests in flight.")
+	gauge.Add(3)
+	gauge.Add(-1)
+	histogram := NewHistogram("test_duration_seconds", "The duration of test requests.", []float64{0.1, 1}, "path")
+	histogram.Observe(0.05, "/c")
+	histogram.Observe(0.1, "/c")
+	histogram.Observe(5, "/c")
+
+	if NewCounter("test_requests_total", "", "path") == nil {
+		t.Error("Expected the registered counter to be returned")
+	}
+
+	var b bytes.Buffer
+	if err := WriteMetrics(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP test_duration_seconds The duration of test requests.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{path="/c",le="0.1"} 2
+test_duration_seconds_bucket{path="/c",le="1"} 2
+test_duration_seconds_bucket{path="/c",le="+Inf"} 3
+test_duration_seconds_sum{path="/c"} 5.15
+test_duration_seconds_count{path="/c"} 3
+# HELP test_in_flight The number of test requests in flight.
+# TYPE test_in_flight gauge
+test_in_flight 2
+# HELP test_requests_total The number of "test" requests.
+# TYPE test_requests_total counter
+test_requests_total{path="/a\"b"} 1
+test_requests_total{path="/c"} 2
+`
+	if !strings.Contains(b.String(), expected) {
+		t.Errorf("Expected the metrics to contain:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
+
+func TestMetricsFilter(t *testing.T) {
+	startFakeBookingApp()
+	resetMetrics("revel_http_requests_total", "revel_http_request_duration_seconds",
+		"revel_http_response_size_bytes", "revel_http_requests_in_flight")
+
+	for _, method := range []string{"GET", "BREW"} {
+		req, _ := http.NewRequest(method, "/hotels", nil)
+		resp := httptest.NewRecorder()
+		c := NewController(NewRequest(req), NewResponse(resp))
+		MetricsFilter(c, []Filter{func(c *Controller, fc []Filter) {
+			c.Action = "Hotels.Index"
+			c.Response.Status = http.StatusCreated
+			c.Result = c.RenderText("created")
+		}})
+		if requestsInFlight.m.series[""].value != 1 {
+			t.Error("Expected the request to be in flight until its response is written")
+		}
+		c.Result.Apply(c.Request, c.Response)
+		if c.Response.Out != resp {
+			t.Error("Expected the response writer to be restored")
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	resp := httptest.NewRecorder()
+	c := NewController(NewRequest(req), NewResponse(resp))
+	MetricsFilter(c, []Filter{func(c *Controller, fc []Filter) {
+		t.Error("Expected /metrics to be served by MetricsFilter")
+	}})
+	c.Result.Apply(c.Request, c.Response)
+
+	body := resp.Body.String()
+	for _, s := range []string{
+		`revel_http_requests_total{action="Hotels.Index",method="GET",status="201"} 1`,
+		`revel_http_response_size_bytes_sum{action="Hotels.Index",method="GET",status="201"} 7`,
+		`revel_http_request_duration_seconds_count{action="Hotels.Index",method="GET",status="201"} 1`,
+		`revel_http_requests_total{action="Hotels.Index",method="OTHER",status="201"} 1`,
+		"revel_http_requests_in_flight 0",
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Expected the metrics to contain %s:\n%s", s, body)
+		}
+	}
+	if contentType := resp.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Expected the Prometheus text format, got %s", contentType)
+	}
+}
+
+func TestMetricsFilterPanic(t *testing.T) {
+	startFakeBookingApp()
+	resetMetrics("revel_http_requests_total", "revel_http_request_duration_seconds",
+		"revel_http_response_size_bytes", "revel_http_requests_in_flight")
+
+	for _, filter := range []Filter{
+		func(c *Controller, fc []Filter) { panic("failed to act") },
+		func(c *Controller, fc []Filter) { c.Result = panicResult{} },
+	} {
+		req, _ := http.NewRequest("GET", "/hotels", nil)
+		c := NewController(NewRequest(req), NewResponse(httptest.NewRecorder()))
+		c.Action = "Hotels.Index"
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("Expected the panic to go on")
+				}
+			}()
+			MetricsFilter(c, []Filter{filter})
+			c.Result.Apply(c.Request, c.Response)
+		}()
+	}
+
+	var b bytes.Buffer
+	WriteMetrics(&b)
+	for _, s := range []string{
+		`revel_http_requests_total{action="Hotels.Index",method="GET",status="500"} 2`,
+		`revel_http_request_duration_seconds_count{action="Hotels.Index",method="GET",status="500"} 2`,
+		"revel_http_requests_in_flight 0",
+	} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("Expected the metrics to contain %s:\n%s", s, b.String())
+		}
+	}
+}
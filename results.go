@@ -17,6 +17,14 @@ import (
 	"golang.org/x/net/websocket"
 )
 
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "results.chunked", Type: ConfigBool, Default: "false", Description: "Whether to stream templates with chunked encoding"},
+		ConfigOption{Name: "results.pretty", Type: ConfigBool, Default: "false", Description: "Whether to indent JSON and XML results"},
+		ConfigOption{Name: "results.trim.html", Type: ConfigBool, Default: "false", Description: "Whether to trim whitespace in HTML templates"},
+	)
+}
+
 type Result interface {
 	Apply(req *Request, resp *Response)
 }
@@ -206,7 +214,12 @@ func (r *RenderTemplateResult) Apply(req *Request, resp *Response) {
 }
 
 func (r *RenderTemplateResult) render(req *Request, resp *Response, wr io.Writer) {
+	_, span := StartSpan(req.Context(), "template "+r.Template.Name())
+	start := time.Now()
 	err := r.Template.Render(wr, r.RenderArgs)
+	templateRenderDuration.Observe(time.Since(start).Seconds(), r.Template.Name())
+	span.SetError(err)
+	span.End()
 	if err == nil {
 		return
 	}
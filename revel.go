@@ -1,17 +1,22 @@
 package revel
 
 import (
+	"fmt"
 	"github.com/agtorre/gocolorize"
 	"github.com/robfig/config"
 	"go/build"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -31,11 +36,12 @@ var (
 	// App details
 	AppName    string // e.g. "sample"
 	AppRoot    string // e.g. "/app1"
+	AppUrl     string // e.g. "https://example.com", from app.url (see appUrl)
 	BasePath   string // e.g. "/Users/robfig/gocode/src/corp/sample"
 	AppPath    string // e.g. "/Users/robfig/gocode/src/corp/sample/app"
 	ViewsPath  string // e.g. "/Users/robfig/gocode/src/corp/sample/app/views"
 	ImportPath string // e.g. "corp/sample"
-	SourcePath string // e.g. "/Users/robfig/gocode/src"
+	SourcePath string // e.g. "/Users/robfig/gocode/src", or the directory of go.mod in module mode
 
 	Config  *MergedConfig
 	RunMode string // Application-defined (by default, "dev" or "prod")
@@ -64,6 +70,14 @@ var (
 	HttpSslCert string // e.g. "/path/to/cert.pem"
 	HttpSslKey  string // e.g. "/path/to/key.pem"
 
+	// Limits of the http.Server started by Run.  Zero means no limit, or the
+	// net/http default.
+	HttpReadTimeout       time.Duration // e.g. 1m, from http.timeout.read
+	HttpWriteTimeout      time.Duration // e.g. 1m, from http.timeout.write
+	HttpIdleTimeout       time.Duration // e.g. 2m, from http.timeout.idle
+	HttpReadHeaderTimeout time.Duration // e.g. 10s, from http.timeout.readheader
+	HttpMaxHeaderBytes    int           // e.g. 1048576, from http.maxheaderbytes
+
 	// All cookies dropped by the framework begin with this prefix.
 	CookiePrefix string
 	// Cookie domain
@@ -94,11 +108,39 @@ var (
 	Initialized bool
 
 	// Private
-	secretKey []byte // Key used to sign cookies. An empty key disables signing.
-	packaged  bool   // If true, this is running from a pre-built package.
+	secretKey []byte    // Key used to sign cookies. An empty key disables signing.
+	packaged  bool      // If true, this is running from a pre-built package.
+	appModule *goModule // The go.mod used to resolve import paths, if in module mode.
+	runPort   int       // The port passed to Run, for the default app.url, or 0.
 )
 
 func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "app.name", Default: "(not set)", Description: "The name of the application (AppName)"},
+		ConfigOption{Name: "app.root", Description: "The path prefix of the application (AppRoot)"},
+		ConfigOption{Name: "app.secret", Description: "The key used to sign cookies"},
+		ConfigOption{Name: "app.url", Description: "The canonical URL of the application for absolute URLs (AppUrl), e.g. https://example.com"},
+		ConfigOption{Name: "mode.dev", Type: ConfigBool, Default: "false", Description: "Whether the run mode is a development mode (DevMode)"},
+		ConfigOption{Name: "build.tags", Type: ConfigList, Description: "The build tags used by the revel command"},
+		ConfigOption{Name: "http.addr", Description: "The address to listen on, or network:address if http.port is 0"},
+		ConfigOption{Name: "http.port", Type: ConfigInt, Default: "9000", Description: "The port to listen on"},
+		ConfigOption{Name: "http.ssl", Type: ConfigBool, Default: "false", Description: "Whether to serve HTTPS"},
+		ConfigOption{Name: "http.sslcert", Description: "The certificate file, if http.ssl is set"},
+		ConfigOption{Name: "http.sslkey", Description: "The private key file, if http.ssl is set"},
+		ConfigOption{Name: "http.timeout.read", Type: ConfigDuration, Default: "1m", Description: "The maximum duration for reading a request, or 0 for no limit"},
+		ConfigOption{Name: "http.timeout.write", Type: ConfigDuration, Default: "1m", Description: "The maximum duration for writing a response, or 0 for no limit"},
+		ConfigOption{Name: "http.timeout.idle", Type: ConfigDuration, Default: "0", Description: "How long to keep idle connections open, or 0 for http.timeout.read"},
+		ConfigOption{Name: "http.timeout.readheader", Type: ConfigDuration, Default: "0", Description: "The maximum duration for reading request headers, or 0 for http.timeout.read"},
+		ConfigOption{Name: "http.maxheaderbytes", Type: ConfigInt, Default: "0", Description: "The maximum size of request headers in bytes, or 0 for 1MB"},
+		ConfigOption{Name: "cookie.prefix", Default: "REVEL", Description: "The prefix of the cookies set by Revel"},
+		ConfigOption{Name: "cookie.domain", Description: "The domain of the cookies set by Revel"},
+		ConfigOption{Name: "cookie.httponly", Type: ConfigBool, Default: "false", Description: "Whether cookies are HttpOnly"},
+		ConfigOption{Name: "cookie.secure", Type: ConfigBool, Default: "false", Description: "Whether cookies are Secure"},
+		ConfigOption{Name: "template.delimiters", Description: `The left and right template delimiters, e.g. "[[ ]]"`},
+		ConfigOption{Name: "module.*", Description: "The import path of a module to load"},
+		ConfigOption{Name: "log.colorize", Type: ConfigBool, Default: "true", Description: "Whether to colorize the log output"},
+	)
+
 	log.SetFlags(INFO.Flags())
 }
 
@@ -109,7 +151,22 @@ func init() {
 //   importPath - the Go import path of the application.
 //   srcPath - the path to the source directory, containing Revel and the app.
 //     If not specified (""), then a functioning Go installation is required.
+//     When the working directory is within a Go module, the app, Revel and
+//     the module.* entries are located through its go.mod (honoring replace
+//     directives and the module cache); otherwise through GOPATH.
+//
+// Init terminates the process if the application can not be initialized.
+// Use InitE to handle the failure instead.
 func Init(mode, importPath, srcPath string) {
+	if err := InitE(mode, importPath, srcPath); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// InitE initializes Revel like Init, but returns an *Error describing the
+// problem (bad app.conf, missing modules, unresolvable import paths, ...)
+// instead of terminating the process.
+func InitE(mode, importPath, srcPath string) error {
 	// Ignore trailing slashes.
 	ImportPath = strings.TrimRight(importPath, "/")
 	SourcePath = srcPath
@@ -119,19 +176,31 @@ func Init(mode, importPath, srcPath string) {
 		gocolorize.SetPlain(true)
 	}
 
-	// If the SourcePath is not specified, find it using build.Import.
-	var revelSourcePath string // may be different from the app source path
+	// If the SourcePath is not specified, find it using the go.mod governing the
+	// working directory, or using build.Import for a GOPATH workspace.
+	appModule, packaged = nil, false
 	if SourcePath == "" {
-		revelSourcePath, SourcePath = findSrcPaths(importPath)
+		if goModFile := findGoMod("."); goModFile != "" {
+			if err := findModulePaths(goModFile, ImportPath); err != nil {
+				return err
+			}
+		} else {
+			revelSourcePath, appSourcePath, err := findSrcPaths(importPath)
+			if err != nil {
+				return err
+			}
+			SourcePath = appSourcePath
+			RevelPath = path.Join(revelSourcePath, filepath.FromSlash(REVEL_IMPORT_PATH))
+			BasePath = path.Join(SourcePath, filepath.FromSlash(importPath))
+		}
 	} else {
 		// If the SourcePath was specified, assume both Revel and the app are within it.
 		SourcePath = path.Clean(SourcePath)
-		revelSourcePath = SourcePath
 		packaged = true
+		RevelPath = path.Join(SourcePath, filepath.FromSlash(REVEL_IMPORT_PATH))
+		BasePath = path.Join(SourcePath, filepath.FromSlash(importPath))
 	}
 
-	RevelPath = path.Join(revelSourcePath, filepath.FromSlash(REVEL_IMPORT_PATH))
-	BasePath = path.Join(SourcePath, filepath.FromSlash(importPath))
 	AppPath = path.Join(BasePath, "app")
 	ViewsPath = path.Join(AppPath, "views")
 
@@ -150,8 +219,14 @@ func Init(mode, importPath, srcPath string) {
 	// Load app.conf
 	var err error
 	Config, err = LoadConfig("app.conf")
+	if revelError, ok := err.(*Error); ok {
+		return revelError
+	}
 	if err != nil || Config == nil {
-		log.Fatalln("Failed to load app.conf:", err)
+		return &Error{
+			Title:       "Failed to load app.conf",
+			Description: fmt.Sprint(err),
+		}
 	}
 	// Ensure that the selected runmode appears in app.conf.
 	// If empty string is passed as the mode, treat it as "DEFAULT"
@@ -159,11 +234,44 @@ func Init(mode, importPath, srcPath string) {
 		mode = config.DEFAULT_SECTION
 	}
 	if !Config.HasSection(mode) {
-		log.Fatalln("app.conf: No mode found:", mode)
+		return &Error{
+			Title:       "app.conf: No mode found",
+			Description: "The run mode " + mode + " does not appear in app.conf",
+		}
 	}
 	Config.SetSection(mode)
+	Config.SetEnvPrefix("REVEL_")
+
+	if err := applyConfig(); err != nil {
+		return err
+	}
+
+	// Configure logging
+	if !Config.BoolDefault("log.colorize", true) {
+		gocolorize.SetPlain(true)
+	}
+
+	if err := configureLogging(); err != nil {
+		return err
+	}
 
-	// Configure properties from app.conf
+	if err := loadModules(); err != nil {
+		return err
+	}
+
+	if err := checkConfig(); err != nil {
+		return err
+	}
+
+	Initialized = true
+	INFO.Printf("Initialized Revel v%s (%s) for %s", VERSION, BUILD_DATE, MINIMUM_GO)
+	return nil
+}
+
+// applyConfig sets the package variables derived from app.conf.  It is run by
+// Init only: the requests read these variables without synchronization, so a
+// reload of Config does not change them.
+func applyConfig() *Error {
 	DevMode = Config.BoolDefault("mode.dev", false)
 	HttpPort = Config.IntDefault("http.port", 9000)
 	HttpAddr = Config.StringDefault("http.addr", "")
@@ -172,67 +280,120 @@ func Init(mode, importPath, srcPath string) {
 	HttpSslKey = Config.StringDefault("http.sslkey", "")
 	if HttpSsl {
 		if HttpSslCert == "" {
-			log.Fatalln("No http.sslcert provided.")
+			return &Error{Title: "app.conf: Invalid SSL configuration", Description: "No http.sslcert provided."}
 		}
 		if HttpSslKey == "" {
-			log.Fatalln("No http.sslkey provided.")
+			return &Error{Title: "app.conf: Invalid SSL configuration", Description: "No http.sslkey provided."}
 		}
 	}
 
+	var err *Error
+	if HttpReadTimeout, err = durationOption("http.timeout.read", time.Minute); err != nil {
+		return err
+	}
+	if HttpWriteTimeout, err = durationOption("http.timeout.write", time.Minute); err != nil {
+		return err
+	}
+	if HttpIdleTimeout, err = durationOption("http.timeout.idle", 0); err != nil {
+		return err
+	}
+	if HttpReadHeaderTimeout, err = durationOption("http.timeout.readheader", 0); err != nil {
+		return err
+	}
+	HttpMaxHeaderBytes = Config.IntDefault("http.maxheaderbytes", 0)
+	if err = loadActionTimeouts(); err != nil {
+		return err
+	}
+
 	AppName = Config.StringDefault("app.name", "(not set)")
 	AppRoot = Config.StringDefault("app.root", "")
+	port := HttpPort
+	if runPort != 0 {
+		port = runPort
+	}
+	if AppUrl, err = appUrl(port); err != nil {
+		return err
+	}
 	CookiePrefix = Config.StringDefault("cookie.prefix", "REVEL")
 	CookieDomain = Config.StringDefault("cookie.domain", "")
 	CookieHttpOnly = Config.BoolDefault("cookie.httponly", false)
 	CookieSecure = Config.BoolDefault("cookie.secure", false)
 	TemplateDelims = Config.StringDefault("template.delimiters", "")
+	if TemplateDelims != "" && len(strings.Split(TemplateDelims, " ")) != 2 {
+		return &Error{
+			Title:       "app.conf: Incorrect format for template.delimiters",
+			Description: "Expected a left and a right delimiter separated by a space, got: " + TemplateDelims,
+		}
+	}
 	if secretStr := Config.StringDefault("app.secret", ""); secretStr != "" {
 		secretKey = []byte(secretStr)
 	}
+	return nil
+}
 
-	// Configure logging
-	if !Config.BoolDefault("log.colorize", true) {
-		gocolorize.SetPlain(true)
+// durationOption returns the value of a duration option, e.g. "90s".
+func durationOption(name string, dfault time.Duration) (time.Duration, *Error) {
+	value, found := Config.String(name)
+	if !found {
+		return dfault, nil
 	}
-
-	TRACE = getLogger("trace")
-	INFO = getLogger("info")
-	WARN = getLogger("warn")
-	ERROR = getLogger("error")
-
-	loadModules()
-
-	Initialized = true
-	INFO.Printf("Initialized Revel v%s (%s) for %s", VERSION, BUILD_DATE, MINIMUM_GO)
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, &Error{
+			Title:       "app.conf: Invalid " + name,
+			Description: "Expected a duration (e.g. 1m30s), got: " + value,
+		}
+	}
+	return d, nil
 }
 
-// Create a logger using log.* directives in app.conf plus the current settings
-// on the default logger.
-func getLogger(name string) *log.Logger {
-	var logger *log.Logger
-
-	// Create a logger with the requested output. (default to stderr)
-	output := Config.StringDefault("log."+name+".output", "stderr")
-	var newlog revelLogs
-
-	switch output {
-	case "stdout":
-		newlog = revelLogs{c: colors[name], w: os.Stdout}
-		logger = newLogger(&newlog)
-	case "stderr":
-		newlog = revelLogs{c: colors[name], w: os.Stderr}
-		logger = newLogger(&newlog)
-	default:
-		if output == "off" {
-			output = os.DevNull
+// appUrl returns the scheme, host and port of the canonical URL of the app,
+// used to build absolute URLs (see ActionDefinition.AbsoluteUrl), e.g.:
+//
+//      app.url = https://example.com
+//
+// By default, it is derived from http.addr, the given port and http.ssl.  As
+// the routes start with the AppRoot, the path of app.url, if any, must be it.
+func appUrl(port int) (string, *Error) {
+	value := Config.StringDefault("app.url", "")
+	if value == "" {
+		scheme, host, defaultPort := "http", HttpAddr, 80
+		if HttpSsl {
+			scheme, defaultPort = "https", 443
+		}
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) || port == 0 {
+			host = "localhost"
+		}
+		if port != 0 && port != defaultPort {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
 		}
+		return scheme + "://" + host, nil
+	}
 
-		file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-		if err != nil {
-			log.Fatalln("Failed to open log file", output, ":", err)
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", &Error{
+			Title:       "app.conf: Invalid app.url",
+			Description: "Expected an http or https URL (e.g. https://example.com), got: " + value,
+		}
+	}
+	if path := strings.TrimSuffix(u.Path, "/"); path != "" && path != AppRoot {
+		return "", &Error{
+			Title:       "app.conf: Invalid app.url",
+			Description: "Expected no path but the app.root (" + AppRoot + "), got: " + u.Path,
 		}
-		logger = newLogger(file)
 	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// Create a logger writing to the given output (see logOutput) using log.*
+// directives in app.conf plus the current settings on the default logger.
+func getLogger(name string, output io.Writer) *log.Logger {
+	// Colorize the console.
+	if output == os.Stdout || output == os.Stderr {
+		output = &revelLogs{c: colors[name], w: output}
+	}
+	logger := newLogger(output)
 
 	// Set the prefix / flags.
 	flags, found := Config.Int("log." + name + ".flags")
@@ -254,41 +415,86 @@ func newLogger(wr io.Writer) *log.Logger {
 
 // findSrcPaths uses the "go/build" package to find the source root for Revel
 // and the app.
-func findSrcPaths(importPath string) (revelSourcePath, appSourcePath string) {
+func findSrcPaths(importPath string) (revelSourcePath, appSourcePath string, err error) {
 	var (
 		gopaths = filepath.SplitList(build.Default.GOPATH)
 		goroot  = build.Default.GOROOT
 	)
 
 	if len(gopaths) == 0 {
-		ERROR.Fatalln("GOPATH environment variable is not set. ",
-			"Please refer to http://golang.org/doc/code.html to configure your Go environment.")
+		return "", "", &Error{
+			Title: "GOPATH environment variable is not set",
+			Description: "Please refer to http://golang.org/doc/code.html " +
+				"to configure your Go environment.",
+		}
 	}
 
 	if ContainsString(gopaths, goroot) {
-		ERROR.Fatalf("GOPATH (%s) must not include your GOROOT (%s). "+
-			"Please refer to http://golang.org/doc/code.html to configure your Go environment.",
-			gopaths, goroot)
+		return "", "", &Error{
+			Title: "GOPATH must not include your GOROOT",
+			Description: fmt.Sprintf("GOPATH (%s) must not include your GOROOT (%s). "+
+				"Please refer to http://golang.org/doc/code.html to configure your Go environment.",
+				gopaths, goroot),
+		}
 	}
 
 	appPkg, err := build.Import(importPath, "", build.FindOnly)
 	if err != nil {
-		ERROR.Fatalln("Failed to import", importPath, "with error:", err)
+		return "", "", &Error{
+			Title:       "Failed to import " + importPath,
+			Description: err.Error(),
+		}
 	}
 
 	revelPkg, err := build.Import(REVEL_IMPORT_PATH, "", build.FindOnly)
 	if err != nil {
-		ERROR.Fatalln("Failed to find Revel with error:", err)
+		return "", "", &Error{
+			Title:       "Failed to find Revel",
+			Description: err.Error(),
+		}
 	}
 
-	return revelPkg.SrcRoot, appPkg.SrcRoot
+	return revelPkg.SrcRoot, appPkg.SrcRoot, nil
+}
+
+// findModulePaths sets BasePath, RevelPath and SourcePath by resolving the app
+// and Revel import paths through the given go.mod file, including its replace
+// directives and the module cache.
+func findModulePaths(goModFile, importPath string) error {
+	mod, err := parseGoMod(goModFile)
+	if err != nil {
+		return &Error{
+			Title:       "Failed to read go.mod",
+			Path:        goModFile,
+			Description: err.Error(),
+		}
+	}
+
+	if BasePath, err = mod.packageDir(importPath); err != nil {
+		return &Error{
+			Title:       "Failed to import " + importPath,
+			Path:        goModFile,
+			Description: err.Error(),
+		}
+	}
+	if RevelPath, err = mod.packageDir(REVEL_IMPORT_PATH); err != nil {
+		return &Error{
+			Title:       "Failed to find Revel",
+			Path:        goModFile,
+			Description: err.Error(),
+		}
+	}
+
+	appModule = mod
+	SourcePath = mod.Dir
+	return nil
 }
 
 type Module struct {
 	Name, ImportPath, Path string
 }
 
-func loadModules() {
+func loadModules() error {
 	for _, key := range Config.Options("module.") {
 		moduleImportPath := Config.StringDefault(key, "")
 		if moduleImportPath == "" {
@@ -297,10 +503,14 @@ func loadModules() {
 
 		modulePath, err := ResolveImportPath(moduleImportPath)
 		if err != nil {
-			log.Fatalln("Failed to load module.  Import of", moduleImportPath, "failed:", err)
+			return &Error{
+				Title:       "Failed to load module " + key[len("module."):],
+				Description: "Import of " + moduleImportPath + " failed: " + err.Error(),
+			}
 		}
 		addModule(key[len("module."):], moduleImportPath, modulePath)
 	}
+	return nil
 }
 
 // ResolveImportPath returns the filesystem path for the given import path.
@@ -309,6 +519,9 @@ func ResolveImportPath(importPath string) (string, error) {
 	if packaged {
 		return path.Join(SourcePath, importPath), nil
 	}
+	if appModule != nil {
+		return appModule.packageDir(importPath)
+	}
 
 	modPkg, err := build.Import(importPath, "", build.FindOnly)
 	if err != nil {
@@ -319,9 +532,9 @@ func ResolveImportPath(importPath string) (string, error) {
 
 func addModule(name, importPath, modulePath string) {
 	Modules = append(Modules, Module{Name: name, ImportPath: importPath, Path: modulePath})
-	if codePath := path.Join(modulePath, "app"); DirExists(codePath) {
+	if codePath := path.Join(modulePath, "app"); sourceDirExists(codePath) {
 		CodePaths = append(CodePaths, codePath)
-		if viewsPath := path.Join(modulePath, "app", "views"); DirExists(viewsPath) {
+		if viewsPath := path.Join(modulePath, "app", "views"); sourceDirExists(viewsPath) {
 			TemplatePaths = append(TemplatePaths, viewsPath)
 		}
 	}
@@ -0,0 +1,118 @@
+package revel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test that InitE reports an unknown run mode instead of exiting.
+func TestInitEUnknownMode(t *testing.T) {
+	err := InitE("staging", "github.com/revel/revel/testdata", "")
+	if err == nil {
+		t.Fatal("Expected an error for a run mode missing from app.conf")
+	}
+	if _, ok := err.(*Error); !ok {
+		t.Errorf("Expected a *revel.Error, got %T: %s", err, err)
+	}
+}
+
+// Test that InitE reports an app that can not be found.
+func TestInitEMissingApp(t *testing.T) {
+	err := InitE("prod", "github.com/revel/revel/no-such-app", "")
+	if err == nil {
+		t.Fatal("Expected an error for an import path that does not exist")
+	}
+}
+
+func TestAppUrl(t *testing.T) {
+	oldConfig, oldAddr, oldSsl, oldRoot := Config, HttpAddr, HttpSsl, AppRoot
+	defer func() {
+		Config, HttpAddr, HttpSsl, AppRoot = oldConfig, oldAddr, oldSsl, oldRoot
+	}()
+
+	for _, test := range []struct {
+		appUrl, addr string
+		port         int
+		ssl          bool
+		root         string
+		expected     string // Empty if invalid
+	}{
+		{"", "", 9000, false, "", "http://localhost:9000"},
+		{"", "0.0.0.0", 80, false, "", "http://localhost"},
+		{"", "app.local", 443, true, "", "https://app.local"},
+		{"", "unix:/tmp/app.sock", 0, false, "", "http://localhost"},
+		{"https://example.com:8443/", "", 9000, false, "", "https://example.com:8443"},
+		{"https://example.com/app1", "", 9000, false, "/app1", "https://example.com"},
+		{"https://example.com/app2", "", 9000, false, "/app1", ""},
+		{"ftp://example.com", "", 9000, false, "", ""},
+		{"example.com", "", 9000, false, "", ""},
+	} {
+		Config = NewEmptyConfig()
+		Config.SetSection("prod")
+		if test.appUrl != "" {
+			Config.SetOption("app.url", test.appUrl)
+		}
+		HttpAddr, HttpSsl, AppRoot = test.addr, test.ssl, test.root
+
+		actual, err := appUrl(test.port)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %s", test.appUrl, actual)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.appUrl, err)
+		}
+		if actual != test.expected {
+			t.Errorf("%q on %s:%d: expected %s, got %s", test.appUrl, test.addr, test.port, test.expected, actual)
+		}
+	}
+}
+
+func TestAppUrlRunPort(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) {
+		if err := os.WriteFile(filepath.Join(dir, "app.conf"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("[prod]\nhttp.port = 9000\n")
+
+	oldConfPaths, oldConfig, oldDevMode, oldRunPort, oldAppUrl := ConfPaths, Config, DevMode, runPort, AppUrl
+	oldPort, oldDomain := HttpPort, CookieDomain
+	defer func() {
+		ConfPaths, Config, DevMode, runPort, AppUrl = oldConfPaths, oldConfig, oldDevMode, oldRunPort, oldAppUrl
+		HttpPort, CookieDomain = oldPort, oldDomain
+	}()
+
+	ConfPaths = []string{dir}
+	c, err := LoadConfig("app.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetSection("prod")
+	Config = c
+	if err := applyConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	// In dev mode, the port passed to Run is proxied from http.port.
+	DevMode = true
+	if err := setRunPort(8080); err != nil || AppUrl != "http://localhost:9000" {
+		t.Errorf("Expected http://localhost:9000 in dev mode, got %s (%v)", AppUrl, err)
+	}
+
+	DevMode = false
+	if err := setRunPort(8080); err != nil || AppUrl != "http://localhost:8080" {
+		t.Errorf("Expected http://localhost:8080, got %s (%v)", AppUrl, err)
+	}
+	write("[prod]\nhttp.port = 9000\ncookie.domain = example.com\n")
+	if err := c.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if AppUrl != "http://localhost:8080" {
+		t.Errorf("Expected the port passed to Run to be kept on reload, got %s", AppUrl)
+	}
+}
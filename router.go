@@ -4,11 +4,11 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/robfig/pathtree"
@@ -22,25 +22,49 @@ type Route struct {
 	MethodName     string   // e.g. "ShowApp", ""
 	FixedParams    []string // e.g. "arg1","arg2","arg3" (CSV formatting)
 	TreePath       string   // e.g. "/GET/app/:id"
+	Name           string   // e.g. "app.show", optional (see ReverseNamed)
 
-	routesPath string // e.g. /Users/robfig/gocode/src/myapp/conf/routes
-	line       int    // e.g. 3
+	routesPath string       // e.g. /Users/robfig/gocode/src/myapp/conf/routes
+	line       int          // e.g. 3
+	handler    http.Handler // Set for the routes added with Router.Handle
+	added      bool         // Set for the routes added with Router.Add and Handle
+
+	args    []*arg         // The wildcards of TreePath, in order
+	pattern *regexp.Regexp // Matches Path, capturing the wildcards
+	err     error          // Set if a constraint is not a valid regular expression
 }
 
 type RouteMatch struct {
-	Action         string // e.g. 404
+	Action         string // e.g. 404, 405, OPTIONS
+	Path           string // The path of the route, e.g. /app/:id
 	ControllerName string // e.g. Application
 	MethodName     string // e.g. ShowApp
 	FixedParams    []string
 	Params         map[string][]string // e.g. {id: 123}
+	Allow          []string            // The methods allowed on the path, for 405 and OPTIONS
+	Handler        http.Handler        // Serves the request instead of an action, see Router.Handle
 }
 
+// arg is a wildcard of a route path.  It only matches the path elements that
+// match its constraint, if any.
 type arg struct {
 	name       string
 	index      int
 	constraint *regexp.Regexp
 }
 
+// The parameters constrained by a regular expression, which may be written
+// either way:
+//
+//      GET /users/{<[0-9]+>id}    Users.Show
+//      GET /users/:id<[0-9]+>     Users.Show
+//
+// Both are equivalent to /users/:id, for URLs whose id is a number.
+var (
+	bracedParamPattern      = regexp.MustCompile(`\{(?:<(.+?)>)?(\w+)\}`)
+	constrainedParamPattern = regexp.MustCompile(`:(\w+)<(.+?)>(/|\.|$)`)
+)
+
 // Prepares the route to be used in matching.
 func NewRoute(method, path, action, fixedArgs, routesPath string, line int) (r *Route) {
 	// Handle fixed arguments
@@ -52,6 +76,11 @@ func NewRoute(method, path, action, fixedArgs, routesPath string, line int) (r *
 		ERROR.Printf("Invalid fixed parameters (%v): for string '%v'", err.Error(), fixedArgs)
 	}
 
+	path, constraints, err := parseConstraints(path)
+	if err != nil {
+		ERROR.Print(err)
+	}
+
 	r = &Route{
 		Method:      strings.ToUpper(method),
 		Path:        path,
@@ -60,7 +89,9 @@ func NewRoute(method, path, action, fixedArgs, routesPath string, line int) (r *
 		TreePath:    treePath(strings.ToUpper(method), path),
 		routesPath:  routesPath,
 		line:        line,
+		err:         err,
 	}
+	r.args, r.pattern = routeArgs(r.TreePath, path, constraints)
 
 	// URL pattern
 	if !strings.HasPrefix(r.Path, "/") {
@@ -77,6 +108,121 @@ func NewRoute(method, path, action, fixedArgs, routesPath string, line int) (r *
 	return
 }
 
+// parseConstraints returns the path with its constrained parameters rewritten
+// as plain wildcards, and the compiled constraints by parameter name.
+func parseConstraints(path string) (string, map[string]*regexp.Regexp, error) {
+	var (
+		constraints = make(map[string]*regexp.Regexp)
+		err         error
+	)
+	compile := func(name, expr string) {
+		if expr == "" {
+			return
+		}
+		constraint, compileErr := regexp.Compile("^(?:" + expr + ")$")
+		if compileErr != nil {
+			if err == nil {
+				err = fmt.Errorf("Invalid constraint of the route parameter %s: %s", name, compileErr)
+			}
+			return
+		}
+		constraints[name] = constraint
+	}
+
+	path = bracedParamPattern.ReplaceAllStringFunc(path, func(param string) string {
+		matches := bracedParamPattern.FindStringSubmatch(param)
+		compile(matches[2], matches[1])
+		return ":" + matches[2]
+	})
+	path = constrainedParamPattern.ReplaceAllStringFunc(path, func(param string) string {
+		matches := constrainedParamPattern.FindStringSubmatch(param)
+		compile(matches[1], matches[2])
+		return ":" + matches[1] + matches[3]
+	})
+	return path, constraints, err
+}
+
+// routeArgs returns the wildcards of the tree path, named like the pathtree
+// does, and a pattern matching the path (without the method) that captures
+// them.
+func routeArgs(treePath, routePath string, constraints map[string]*regexp.Regexp) ([]*arg, *regexp.Regexp) {
+	var args []*arg
+	elements := strings.Split(treePath, "/")
+	for i, el := range elements {
+		if el == "" || (el[0] != ':' && el[0] != '*') {
+			continue
+		}
+		name := el[1:]
+		if i == len(elements)-1 {
+			name = strings.TrimSuffix(name, path.Ext(name))
+		}
+		args = append(args, &arg{name: name, index: len(args), constraint: constraints[name]})
+	}
+
+	elements = strings.Split(routePath, "/")
+	for i, el := range elements {
+		switch {
+		case el == "":
+		case el[0] == ':' && i == len(elements)-1:
+			elements[i] = "([^/]+)" + regexp.QuoteMeta(path.Ext(el))
+		case el[0] == ':':
+			elements[i] = "([^/]+)"
+		case el[0] == '*':
+			elements[i] = "(.*)"
+		default:
+			elements[i] = regexp.QuoteMeta(el)
+		}
+	}
+	return args, regexp.MustCompile("^" + strings.Join(elements, "/") + "$")
+}
+
+// constrained returns true if any of the route parameters has a constraint.
+func (route *Route) constrained() bool {
+	for _, arg := range route.args {
+		if arg.constraint != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// params returns the route parameters for the values of its wildcards, or
+// false if any of them violates its constraint.
+func (route *Route) params(expansions []string) (url.Values, bool) {
+	var params url.Values
+	if len(expansions) > 0 {
+		params = make(url.Values)
+	}
+	for i, value := range expansions {
+		if i >= len(route.args) {
+			break
+		}
+		arg := route.args[i]
+		if arg.constraint != nil && !arg.constraint.MatchString(value) {
+			return nil, false
+		}
+		params[arg.name] = []string{value}
+	}
+	return params, true
+}
+
+// violatedArg returns the first route parameter whose given value violates
+// its constraint, if any.  Missing values do not.
+func (route *Route) violatedArg(argValues map[string]string) *arg {
+	for _, arg := range route.args {
+		if value, ok := argValues[arg.name]; ok && arg.constraint != nil && !arg.constraint.MatchString(value) {
+			return arg
+		}
+	}
+	return nil
+}
+
+// allowsMethod returns true if the route serves requests of the given method.
+func (route *Route) allowsMethod(method string) bool {
+	return route.Method == method || route.Method == "*" ||
+		(route.Method == "GET" && method == "HEAD")
+}
+
 func treePath(method, path string) string {
 	if method == "*" {
 		method = ":METHOD"
@@ -85,14 +231,33 @@ func treePath(method, path string) string {
 }
 
 type Router struct {
-	Routes []*Route
-	Tree   *pathtree.Node
-	path   string // path to the routes file
+	Routes     []*Route
+	Tree       *pathtree.Node
+	path       string        // path to the routes file
+	methods    []string      // The methods of the routes, e.g. GET, POST
+	fileRoutes []*Route      // The routes of the file, as of the last Refresh
+	added      []*addedRoute // The routes added with Add and Handle
+	loaded     bool          // Set once the routing table has been calculated
+	handlers   bool          // Set if some routes are served by http.Handlers
+}
+
+// addedRoute is a route added in code, rather than in the routes file.
+type addedRoute struct {
+	method, path, action string
+	handler              http.Handler
 }
 
 var notFound = &RouteMatch{Action: "404"}
 
 func (router *Router) Route(req *http.Request) *RouteMatch {
+	// The http.Handlers are given the request as is, without its body parsed
+	// for a method override.
+	if router.handlers {
+		if route := router.match(req.Method, req.URL.Path); route != nil && route.Handler != nil {
+			return route
+		}
+	}
+
 	// Override method if set in header
 	var method string
 
@@ -106,27 +271,99 @@ func (router *Router) Route(req *http.Request) *RouteMatch {
 		req.Method = method
 	}
 
-	leaf, expansions := router.Tree.Find(treePath(req.Method, req.URL.Path))
+	return router.match(req.Method, req.URL.Path)
+}
+
+// match returns the route matching the given method and path, without looking
+// at the request itself.  If the path only matches routes of other methods,
+// it returns a 405 match, or an OPTIONS match to answer OPTIONS requests
+// automatically, with the allowed methods.
+func (router *Router) match(method, path string) *RouteMatch {
+	if route := router.find(method, path); route != nil {
+		return route
+	}
+
+	allow := router.allowedMethods(path)
+	if len(allow) == 0 {
+		return nil
+	}
+	if method == "OPTIONS" {
+		return &RouteMatch{Action: "OPTIONS", Allow: allow}
+	}
+	return &RouteMatch{Action: "405", Allow: allow}
+}
+
+// allowedMethods returns the methods of the routes matching the path, sorted,
+// plus HEAD for GET and OPTIONS, or nil if there are none.
+func (router *Router) allowedMethods(path string) []string {
+	var allow []string
+	for _, method := range router.methods {
+		if route := router.find(method, path); route != nil && route != notFound {
+			allow = append(allow, method)
+		}
+	}
+	if len(allow) == 0 {
+		return nil
+	}
+	if ContainsString(allow, "GET") && !ContainsString(allow, "HEAD") {
+		allow = append(allow, "HEAD")
+	}
+	if !ContainsString(allow, "OPTIONS") {
+		allow = append(allow, "OPTIONS")
+	}
+	sort.Strings(allow)
+	return allow
+}
+
+// find returns the route matching the given method and path, if any.
+func (router *Router) find(method, path string) *RouteMatch {
+	leaf, expansions := router.Tree.Find(treePath(method, path))
 
 	if leaf == nil {
 		return nil
 	}
-	route := leaf.Value.(*Route)
+	for _, route := range *leaf.Value.(*[]*Route) {
+		if params, ok := route.params(expansions); ok {
+			return route.match(params)
+		}
+	}
 
-	// Create a map of the route parameters.
-	var params url.Values
-	if len(expansions) > 0 {
-		params = make(url.Values)
-		for i, v := range expansions {
-			params[leaf.Wildcards[i]] = []string{v}
+	// The constraints ruled out the best match: fall through to the other
+	// routes, in order.
+	for _, route := range router.Routes {
+		if !route.allowsMethod(method) {
+			continue
+		}
+		matches := route.pattern.FindStringSubmatch(path)
+		if matches == nil {
+			continue
+		}
+		expansions = matches[1:]
+		if route.Method == "*" {
+			expansions = append([]string{method}, expansions...)
+		}
+		if params, ok := route.params(expansions); ok {
+			return route.match(params)
 		}
 	}
+	return nil
+}
 
+// match returns the RouteMatch of the route for the given parameters.
+func (route *Route) match(params url.Values) *RouteMatch {
 	// Special handling for explicit 404's.
 	if route.Action == "404" {
 		return notFound
 	}
 
+	if route.handler != nil {
+		return &RouteMatch{
+			Path:    route.Path,
+			Params:  params,
+			Handler: route.handler,
+		}
+	}
+
 	// If the action is variablized, replace into it with the captured args.
 	controllerName, methodName := route.ControllerName, route.MethodName
 	if controllerName[0] == ':' {
@@ -137,6 +374,7 @@ func (router *Router) Route(req *http.Request) *RouteMatch {
 	}
 
 	return &RouteMatch{
+		Path:           route.Path,
 		ControllerName: controllerName,
 		MethodName:     methodName,
 		Params:         params,
@@ -147,25 +385,206 @@ func (router *Router) Route(req *http.Request) *RouteMatch {
 // Refresh re-reads the routes file and re-calculates the routing table.
 // Returns an error if a specified action could not be found.
 func (router *Router) Refresh() (err *Error) {
-	router.Routes, err = parseRoutesFile(router.path, "", true)
+	router.fileRoutes, err = parseRoutesFile(router.path, "", true)
 	if err != nil {
 		return
 	}
-	err = router.updateTree()
+	err = router.load()
 	return
 }
 
+// load calculates the routing table from the routes added in code, which take
+// precedence, and the routes of the file.
+func (router *Router) load() *Error {
+	routes := make([]*Route, 0, len(router.added)+len(router.fileRoutes))
+	for _, added := range router.added {
+		addedRoutes, err := added.routes()
+		if err != nil {
+			return &Error{
+				Title:       "Route validation error",
+				Description: fmt.Sprintf("%s %s %s: %s", added.method, added.path, added.action, err),
+			}
+		}
+		routes = append(routes, addedRoutes...)
+	}
+
+	// The added routes shadow the routes of the file with the same path.
+	shadowed := make(map[string]bool)
+	for _, route := range routes {
+		if !route.constrained() {
+			shadowed[treeKey(route.TreePath)] = true
+			if route.Method == "GET" {
+				shadowed[treeKey(treePath("HEAD", route.Path))] = true
+			}
+		}
+	}
+	for _, route := range router.fileRoutes {
+		if shadowed[treeKey(route.TreePath)] {
+			INFO.Printf("The route %s %s (%s:%d) is shadowed by a route added in code",
+				route.Method, route.Path, route.routesPath, route.line+1)
+			continue
+		}
+		routes = append(routes, route)
+	}
+	router.Routes = routes
+	router.loaded = true
+	return router.updateTree()
+}
+
+// routes returns the routes of the routing table for the added route, under
+// the AppRoot like those of the routes file.
+func (added *addedRoute) routes() ([]*Route, error) {
+	path := AppRoot + added.path
+	if added.handler == nil {
+		route := NewRoute(added.method, path, added.action, "", "", 0)
+		route.added = true
+		if route.err != nil {
+			return nil, route.err
+		}
+		if err := validateRoute(route); err != nil {
+			return nil, err
+		}
+		return []*Route{route}, nil
+	}
+
+	// The prefix itself, and everything under it.
+	var routes []*Route
+	prefix := strings.TrimSuffix(path, "/")
+	if prefix != "" {
+		routes = append(routes, NewRoute("*", prefix, "", "", "", 0))
+	}
+	routes = append(routes, NewRoute("*", prefix+"/*path", "", "", "", 0))
+	for _, route := range routes {
+		route.handler, route.added = added.handler, true
+	}
+	return routes, nil
+}
+
+// The methods of the routes, like in the routes file.
+var routeMethodPattern = regexp.MustCompile("^(GET|POST|PUT|DELETE|PATCH|OPTIONS|HEAD|WS|\\*)$")
+
+// Add adds a route to the router in code, as if it was in the routes file,
+// e.g.:
+//
+//      revel.MainRouter.Add("GET", "/api/ping", "Api.Ping")
+//
+// The path is under the AppRoot, and may have wildcards and constraints like
+// in the routes file.  The routes added in code take precedence over those of
+// the file: a route of the file with the same method and path is dropped,
+// unless the added route has constraints to fall through.  They are kept when
+// the file is reloaded.
+//
+// Like the routes of the file, the action is validated once the routing table
+// is calculated: right away if it already is, or else when the app starts, so
+// routes may be added in init functions as well as OnAppStart hooks.  Routes
+// are meant to be added at startup, not while serving requests.
+func (router *Router) Add(method, path, action string) error {
+	method = strings.ToUpper(method)
+	if !routeMethodPattern.MatchString(method) {
+		return fmt.Errorf("Invalid route method: %s", method)
+	}
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("Absolute URL required: %s", path)
+	}
+	if _, _, err := parseConstraints(path); err != nil {
+		return err
+	}
+	return router.add(&addedRoute{method: method, path: path, action: action})
+}
+
+// Handle adds routes serving every request under the prefix (e.g.
+// "/debug/pprof/") with the given http.Handler, instead of an action, e.g.:
+//
+//      revel.MainRouter.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
+//
+// The handler is given the request as is, with the full path; the filters
+// before the RouterFilter apply, the following ones do not.  See Add.
+func (router *Router) Handle(prefix string, handler http.Handler) error {
+	if !strings.HasPrefix(prefix, "/") {
+		return fmt.Errorf("Absolute URL required: %s", prefix)
+	}
+	return router.add(&addedRoute{method: "*", path: prefix, handler: handler})
+}
+
+func (router *Router) add(added *addedRoute) error {
+	router.added = append(router.added, added)
+	if !router.loaded {
+		return nil
+	}
+	if err := router.load(); err != nil {
+		router.added = router.added[:len(router.added)-1]
+		router.load()
+		return err
+	}
+	return nil
+}
+
+// The wildcards of a tree path, whose names do not matter to the pathtree.
+var wildcardPattern = regexp.MustCompile(`/([:*])[^/.]+`)
+
+// treeKey returns the tree path without the names of its wildcards, which is
+// the same for the routes sharing a leaf.
+func treeKey(treePath string) string {
+	return wildcardPattern.ReplaceAllString(treePath, "/$1")
+}
+
 func (router *Router) updateTree() *Error {
 	router.Tree = pathtree.New()
+	router.methods = nil
+	router.handlers = false
+
+	// Routes with the same path share a leaf, in order, as long as the earlier
+	// ones have constraints to fall through.
+	leaves := make(map[string]*[]*Route)
+	names := make(map[string]bool)
+	add := func(treePath string, route *Route) error {
+		key := treeKey(treePath)
+		routes, ok := leaves[key]
+		if !ok {
+			routes = &[]*Route{route}
+			leaves[key] = routes
+			return router.Tree.Add(treePath, routes)
+		}
+		for _, earlier := range *routes {
+			if !earlier.constrained() {
+				return fmt.Errorf("duplicate path")
+			}
+		}
+		*routes = append(*routes, route)
+		return nil
+	}
+
 	for _, route := range router.Routes {
-		err := router.Tree.Add(route.TreePath, route)
+		if route.Name != "" {
+			if names[route.Name] {
+				return routeError(fmt.Errorf("duplicate route name: %s", route.Name), route.routesPath, "", route.line)
+			}
+			names[route.Name] = true
+		}
+
+		if route.handler != nil {
+			router.handlers = true
+		}
+
+		// Web sockets are not requested with a method of their own.
+		if route.Method != "*" && route.Method != "WS" && !ContainsString(router.methods, route.Method) {
+			router.methods = append(router.methods, route.Method)
+		}
+
+		err := add(route.TreePath, route)
 
 		// Allow GETs to respond to HEAD requests.
 		if err == nil && route.Method == "GET" {
-			err = router.Tree.Add(treePath("HEAD", route.Path), route)
+			err = add(treePath("HEAD", route.Path), route)
 		}
 
 		// Error adding a route to the pathtree.
+		if err != nil && route.added {
+			return &Error{
+				Title:       "Route validation error",
+				Description: fmt.Sprintf("%s %s (added in code): %s", route.Method, route.Path, err),
+			}
+		}
 		if err != nil {
 			return routeError(err, route.routesPath, "", route.line)
 		}
@@ -175,7 +594,7 @@ func (router *Router) updateTree() *Error {
 
 // parseRoutesFile reads the given routes file and returns the contained routes.
 func parseRoutesFile(routesPath, joinedPath string, validate bool) ([]*Route, *Error) {
-	contentBytes, err := ioutil.ReadFile(routesPath)
+	contentBytes, err := readSourceFile(routesPath)
 	if err != nil {
 		return nil, &Error{
 			Title:       "Failed to load routes file",
@@ -210,7 +629,7 @@ func parseRoutes(routesPath, joinedPath, content string, validate bool) ([]*Rout
 		}
 
 		// A single route
-		method, path, action, fixedArgs, found := parseRouteLine(line)
+		method, path, action, fixedArgs, name, found := parseRouteLine(line)
 		if !found {
 			continue
 		}
@@ -237,6 +656,10 @@ func parseRoutes(routesPath, joinedPath, content string, validate bool) ([]*Rout
 		}
 
 		route := NewRoute(method, path, action, fixedArgs, routesPath, n)
+		if route.err != nil {
+			return nil, routeError(route.err, routesPath, content, n)
+		}
+		route.Name = name
 		routes = append(routes, route)
 
 		if validate {
@@ -268,12 +691,8 @@ func validateRoute(route *Route) error {
 		return nil
 	}
 
-	var c Controller
-	if err := c.SetAction(parts[0], parts[1]); err != nil {
-		return err
-	}
-
-	return nil
+	_, _, err := findAction(parts[0], parts[1])
+	return err
 }
 
 // routeError adds context to a simple error message.
@@ -282,8 +701,8 @@ func routeError(err error, routesPath, content string, n int) *Error {
 		return revelError
 	}
 	// Load the route file content if necessary
-	if content == "" {
-		contentBytes, err := ioutil.ReadFile(routesPath)
+	if content == "" && routesPath != "" {
+		contentBytes, err := readSourceFile(routesPath)
 		if err != nil {
 			ERROR.Printf("Failed to read route file %s: %s\n", routesPath, err)
 		} else {
@@ -317,18 +736,19 @@ func getModuleRoutes(moduleName, joinedPath string, validate bool) ([]*Route, *E
 // 4: path
 // 5: action
 // 6: fixedargs
+// 7: name, e.g. "GET /v1/users Users.List as v1.users"
 var routePattern *regexp.Regexp = regexp.MustCompile(
 	"(?i)^(GET|POST|PUT|DELETE|PATCH|OPTIONS|HEAD|WS|\\*)" +
 		"[(]?([^)]*)(\\))?[ \t]+" +
 		"(.*/[^ \t]*)[ \t]+([^ \t(]+)" +
-		`\(?([^)]*)\)?[ \t]*$`)
+		`\(?([^)]*?)\)?(?:[ \t]+as[ \t]+([\w.-]+))?[ \t]*$`)
 
-func parseRouteLine(line string) (method, path, action, fixedArgs string, found bool) {
+func parseRouteLine(line string) (method, path, action, fixedArgs, name string, found bool) {
 	var matches []string = routePattern.FindStringSubmatch(line)
 	if matches == nil {
 		return
 	}
-	method, path, action, fixedArgs = matches[1], matches[4], matches[5], matches[6]
+	method, path, action, fixedArgs, name = matches[1], matches[4], matches[5], matches[6], matches[7]
 	found = true
 	return
 }
@@ -340,6 +760,8 @@ func NewRouter(routesPath string) *Router {
 	}
 }
 
+// ActionDefinition is the result of reverse routing.  The Host is that of
+// app.url, e.g. "example.com:8443", and the Url is relative to it.
 type ActionDefinition struct {
 	Host, Method, Url, Action string
 	Star                      bool
@@ -350,6 +772,16 @@ func (a *ActionDefinition) String() string {
 	return a.Url
 }
 
+// AbsoluteUrl returns the URL with the scheme, host and port of app.url, e.g.
+// for emails, OAuth callbacks and Location headers.
+func (a *ActionDefinition) AbsoluteUrl() string {
+	base := AppUrl
+	if a.Method == "WS" {
+		base = "ws" + strings.TrimPrefix(base, "http")
+	}
+	return base + a.Url
+}
+
 func (router *Router) Reverse(action string, argValues map[string]string) *ActionDefinition {
 	actionSplit := strings.Split(action, ".")
 	if len(actionSplit) != 2 {
@@ -358,6 +790,7 @@ func (router *Router) Reverse(action string, argValues map[string]string) *Actio
 	}
 	controllerName, methodName := actionSplit[0], actionSplit[1]
 
+	var violated *arg
 	for _, route := range router.Routes {
 		// Skip routes without either a ControllerName or MethodName
 		if route.ControllerName == "" || route.MethodName == "" {
@@ -378,60 +811,133 @@ func (router *Router) Reverse(action string, argValues map[string]string) *Actio
 			argValues[route.MethodName[1:]] = methodName
 		}
 
-		// Build up the URL.
-		var (
-			queryValues  = make(url.Values)
-			pathElements = strings.Split(route.Path, "/")
-		)
-		for i, el := range pathElements {
-			if el == "" || (el[0] != ':' && el[0] != '*') {
-				continue
+		// Skip the routes that would not match the URL.
+		if arg := route.violatedArg(argValues); arg != nil {
+			violated = arg
+			if controllerWildcard {
+				delete(argValues, route.ControllerName[1:])
 			}
-
-			val, ok := argValues[el[1:]]
-			if !ok {
-				val = "<nil>"
-				ERROR.Print("revel/router: reverse route missing route arg ", el[1:])
+			if methodWildcard {
+				delete(argValues, route.MethodName[1:])
 			}
-			pathElements[i] = val
-			delete(argValues, el[1:])
 			continue
 		}
 
-		// Add any args that were not inserted into the path into the query string.
-		for k, v := range argValues {
-			queryValues.Set(k, v)
+		return route.actionDefinition(action, argValues)
+	}
+	if violated != nil {
+		ERROR.Printf("revel/router: reverse route arg %s=%q does not match %s",
+			violated.name, argValues[violated.name], violated.constraint)
+	}
+	ERROR.Println("Failed to find reverse route:", action, argValues)
+	return nil
+}
+
+// ReverseNamed returns the URL of the route with the given name, set with "as
+// <name>" at the end of its line in the routes file, e.g. for the route:
+//
+//      GET /v2/users/:id    Users.Show    as v2.user
+//
+// ReverseNamed("v2.user", map[string]string{"id": "3"}) returns /v2/users/3.
+// This allows to pick a route when several map to the same action.
+func (router *Router) ReverseNamed(name string, argValues map[string]string) *ActionDefinition {
+	route := router.namedRoute(name)
+	if route == nil {
+		ERROR.Println("revel/router: no route named", name)
+		return nil
+	}
+	if arg := route.violatedArg(argValues); arg != nil {
+		ERROR.Printf("revel/router: reverse route arg %s=%q does not match %s",
+			arg.name, argValues[arg.name], arg.constraint)
+		return nil
+	}
+	return route.actionDefinition(route.Action, argValues)
+}
+
+func (router *Router) namedRoute(name string) *Route {
+	for _, route := range router.Routes {
+		if route.Name == name {
+			return route
 		}
+	}
+	return nil
+}
 
-		// Calculate the final URL and Method
-		url := strings.Join(pathElements, "/")
-		if len(queryValues) > 0 {
-			url += "?" + queryValues.Encode()
+// pathArgNames returns the names of the wildcards of the path, in order.
+func (route *Route) pathArgNames() []string {
+	var names []string
+	for _, arg := range route.args {
+		if route.Method == "*" && arg.index == 0 {
+			continue // The :METHOD of the tree path
 		}
+		names = append(names, arg.name)
+	}
+	return names
+}
 
-		method := route.Method
-		star := false
-		if route.Method == "*" {
-			method = "GET"
-			star = true
+// actionDefinition builds the URL of the route for the given arguments: those
+// of the path wildcards, and the others in the query string.
+func (route *Route) actionDefinition(action string, argValues map[string]string) *ActionDefinition {
+	// Build up the URL.
+	var (
+		queryValues  = make(url.Values)
+		pathElements = strings.Split(route.Path, "/")
+	)
+	for i, el := range pathElements {
+		if el == "" || (el[0] != ':' && el[0] != '*') {
+			continue
 		}
 
-		return &ActionDefinition{
-			Url:    url,
-			Method: method,
-			Star:   star,
-			Action: action,
-			Args:   argValues,
-			Host:   "TODO",
+		val, ok := argValues[el[1:]]
+		if !ok {
+			val = "<nil>"
+			ERROR.Print("revel/router: reverse route missing route arg ", el[1:])
 		}
+		pathElements[i] = val
+		delete(argValues, el[1:])
+		continue
+	}
+
+	// Add any args that were not inserted into the path into the query string.
+	for k, v := range argValues {
+		queryValues.Set(k, v)
+	}
+
+	// Calculate the final URL and Method
+	url := strings.Join(pathElements, "/")
+	if len(queryValues) > 0 {
+		url += "?" + queryValues.Encode()
+	}
+
+	_, host, _ := strings.Cut(AppUrl, "://")
+	method := route.Method
+	star := false
+	if route.Method == "*" {
+		method = "GET"
+		star = true
+	}
+
+	return &ActionDefinition{
+		Url:    url,
+		Method: method,
+		Star:   star,
+		Action: action,
+		Args:   argValues,
+		Host:   host,
 	}
-	ERROR.Println("Failed to find reverse route:", action, argValues)
-	return nil
 }
 
 func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "watch.routes", Type: ConfigBool, Default: "true", Description: "Whether to reload the routes when they change"},
+	)
+
+	// The router exists before the app starts, so that routes may be added in
+	// init functions.
+	MainRouter = NewRouter("")
+
 	OnAppStart(func() {
-		MainRouter = NewRouter(path.Join(BasePath, "conf", "routes"))
+		MainRouter.path = path.Join(BasePath, "conf", "routes")
 		err := MainRouter.Refresh()
 		if MainWatcher != nil && Config.BoolDefault("watch.routes", true) {
 			MainWatcher.Listen(MainRouter, MainRouter.path)
@@ -446,7 +952,7 @@ func RouterFilter(c *Controller, fc []Filter) {
 	// Figure out the Controller/Action
 	var route *RouteMatch = MainRouter.Route(c.Request.Request)
 	if route == nil {
-		c.Result = c.NotFound("No matching route found: " + c.Request.RequestURI)
+		c.Result = c.NotFound("No matching route found: %s", c.Request.RequestURI)
 		return
 	}
 
@@ -456,9 +962,33 @@ func RouterFilter(c *Controller, fc []Filter) {
 		return
 	}
 
+	// The path exists for other methods.
+	if route.Action == "405" || route.Action == "OPTIONS" {
+		c.Response.Out.Header().Set("Allow", strings.Join(route.Allow, ", "))
+	}
+	if route.Action == "405" {
+		c.Response.Status = http.StatusMethodNotAllowed
+		c.Result = c.RenderError(&Error{
+			Title:       "Method not allowed",
+			Description: "Method " + c.Request.Method + " is not allowed (valid: " + strings.Join(route.Allow, ", ") + ")",
+		})
+		return
+	}
+	if route.Action == "OPTIONS" {
+		c.Result = optionsResult{}
+		return
+	}
+
+	// The route may be served by a http.Handler instead of an action.
+	if route.Handler != nil {
+		c.Params.Route = route.Params
+		c.Result = handlerResult{route.Handler}
+		return
+	}
+
 	// Set the action.
 	if err := c.SetAction(route.ControllerName, route.MethodName); err != nil {
-		c.Result = c.NotFound(err.Error())
+		c.Result = c.NotFound("%s", err.Error())
 		return
 	}
 
@@ -483,6 +1013,48 @@ func RouterFilter(c *Controller, fc []Filter) {
 	fc[0](c, fc[1:])
 }
 
+// optionsResult answers an OPTIONS request for which there is no route, with
+// the Allow header set by the RouterFilter.
+type optionsResult struct{}
+
+func (r optionsResult) Apply(req *Request, resp *Response) {
+	resp.Status = http.StatusNoContent
+	resp.Out.WriteHeader(resp.Status)
+}
+
+// handlerResult serves the request with a http.Handler added with
+// Router.Handle.
+type handlerResult struct {
+	handler http.Handler
+}
+
+func (r handlerResult) Apply(req *Request, resp *Response) {
+	r.handler.ServeHTTP(&statusResponseWriter{resp.Out, resp}, req.Request)
+}
+
+// statusResponseWriter records the status written by a http.Handler in the
+// Response, for the filters (e.g. the access log).
+type statusResponseWriter struct {
+	http.ResponseWriter
+	resp *Response
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.resp.Status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // Override allowed http methods via form or browser param
 func HttpMethodOverride(c *Controller, fc []Filter) {
 	// An array of HTTP verbs allowed.
@@ -2,7 +2,10 @@ package revel
 
 import (
 	"fmt"
+	"html/template"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
@@ -78,6 +81,38 @@ var routeTestCases = map[string]*Route{
 		FixedParams: []string{},
 	},
 
+	"get /users/{<[0-9]+>id} Users.Show": &Route{
+		Method:      "GET",
+		Path:        "/users/:id",
+		Action:      "Users.Show",
+		FixedParams: []string{},
+	},
+
+	`get /users/:id<\d{2,}>/posts/:slug<[a-z-]+>.json Posts.Show`: &Route{
+		Method:      "GET",
+		Path:        "/users/:id/posts/:slug.json",
+		Action:      "Posts.Show",
+		FixedParams: []string{},
+	},
+
+	"GET /v2/users Users.List as v2.users": &Route{
+		Method:      "GET",
+		Path:        "/v2/users",
+		Action:      "Users.List",
+		FixedParams: []string{},
+		Name:        "v2.users",
+	},
+
+	`GET /public/*filepath Static.Serve("public") as public`: &Route{
+		Method: "GET",
+		Path:   "/public/*filepath",
+		Action: "Static.Serve",
+		FixedParams: []string{
+			"public",
+		},
+		Name: "public",
+	},
+
 	`GET / Application.Index("Test", "Test2")`: &Route{
 		Method: "GET",
 		Path:   "/",
@@ -92,7 +127,7 @@ var routeTestCases = map[string]*Route{
 // Run the test cases above.
 func TestComputeRoute(t *testing.T) {
 	for routeLine, expected := range routeTestCases {
-		method, path, action, fixedArgs, found := parseRouteLine(routeLine)
+		method, path, action, fixedArgs, name, found := parseRouteLine(routeLine)
 		if !found {
 			t.Error("Failed to parse route line:", routeLine)
 			continue
@@ -101,6 +136,7 @@ func TestComputeRoute(t *testing.T) {
 		eq(t, "Method", actual.Method, expected.Method)
 		eq(t, "Path", actual.Path, expected.Path)
 		eq(t, "Action", actual.Action, expected.Action)
+		eq(t, "Name", name, expected.Name)
 		if t.Failed() {
 			t.Fatal("Failed on route:", routeLine)
 		}
@@ -337,6 +373,10 @@ var reverseRoutingTestCases = map[*ReverseRouteArgs]*ActionDefinition{
 }
 
 func TestReverseRouting(t *testing.T) {
+	oldAppUrl := AppUrl
+	defer func() { AppUrl = oldAppUrl }()
+	AppUrl = "https://example.com:8443"
+
 	router := NewRouter("")
 	router.Routes, _ = parseRoutes("", "", TEST_ROUTES, false)
 	for routeArgs, expected := range reverseRoutingTestCases {
@@ -348,7 +388,354 @@ func TestReverseRouting(t *testing.T) {
 		eq(t, "Method", actual.Method, expected.Method)
 		eq(t, "Star", actual.Star, expected.Star)
 		eq(t, "Action", actual.Action, expected.Action)
+		eq(t, "Host", actual.Host, "example.com:8443")
+		eq(t, "AbsoluteUrl", actual.AbsoluteUrl(), "https://example.com:8443"+expected.Url)
+	}
+}
+
+const CONSTRAINED_ROUTES = `
+GET   /users/new                 Users.New
+GET   /users/{<[0-9]+>id}        Users.Show
+GET   /users/:name<[a-z]+>       Users.ShowByName
+GET   /files/:id<\d+>.json       Files.Show
+GET   /orders/:id<\d+>           Orders.Show
+GET   /orders/*path              Orders.Search
+`
+
+func TestRouteConstraints(t *testing.T) {
+	router := NewRouter("")
+	var err *Error
+	router.Routes, err = parseRoutes("", "", CONSTRAINED_ROUTES, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = router.updateTree(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		method, path, action, param, value string
+	}{
+		{"GET", "/users/123", "Users.Show", "id", "123"},
+		{"HEAD", "/users/123", "Users.Show", "id", "123"},
+		{"GET", "/users/bob", "Users.ShowByName", "name", "bob"},
+		{"GET", "/users/new", "Users.New", "", ""},
+		{"GET", "/users/Bob", "", "", ""},
+		{"GET", "/files/42.json", "Files.Show", "id", "42"},
+		{"GET", "/files/abc.json", "", "", ""},
+		{"GET", "/orders/42", "Orders.Show", "id", "42"},
+		{"GET", "/orders/recent", "Orders.Search", "path", "recent"},
+	} {
+		route := router.match(test.method, test.path)
+		if test.action == "" {
+			if route != nil {
+				t.Errorf("%s %s: expected no route, got %s.%s", test.method, test.path, route.ControllerName, route.MethodName)
+			}
+			continue
+		}
+		if route == nil {
+			t.Errorf("%s %s: expected %s, got no route", test.method, test.path, test.action)
+			continue
+		}
+		eq(t, test.path, route.ControllerName+"."+route.MethodName, test.action)
+		if test.param != "" {
+			eq(t, test.path+" "+test.param, url.Values(route.Params).Get(test.param), test.value)
+		}
+	}
+
+	// Reverse routing skips the routes whose constraints are violated.
+	eq(t, "Reverse", router.Reverse("Users.Show", map[string]string{"id": "123"}).Url, "/users/123")
+	eq(t, "Reverse", router.Reverse("Users.Show", map[string]string{"id": "bob"}) == nil, true)
+	eq(t, "Reverse", router.Reverse("Files.Show", map[string]string{"id": "x"}) == nil, true)
+
+	// A route can not follow one with the same path and no constraint.
+	router.Routes, _ = parseRoutes("", "", "GET /a/:x A.B\nGET /a/:y<[0-9]+> A.C", false)
+	if router.updateTree() == nil {
+		t.Error("Expected a duplicate path error")
+	}
+
+	// Invalid constraints fail the routes file.
+	if _, err = parseRoutes("", "", "GET /a/:x<[0-9> A.B", false); err == nil {
+		t.Error("Expected an error for an invalid constraint")
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	router := NewRouter("")
+	router.Routes, _ = parseRoutes("", "", TEST_ROUTES+`
+GET     /app/:id/edit              Application.Edit
+PUT     /app/:id/edit              Application.Update
+OPTIONS /test/                     Application.Options
+`, false)
+	router.updateTree()
+
+	for _, test := range []struct {
+		method, path, action, allow string
+	}{
+		{"DELETE", "/app/123/edit", "405", "GET, HEAD, OPTIONS, PUT"},
+		{"OPTIONS", "/app/123/edit", "OPTIONS", "GET, HEAD, OPTIONS, PUT"},
+		{"GET", "/app/123", "", ""}, // By the catch-all route
+		{"OPTIONS", "/test/", "", ""},
+	} {
+		route := router.match(test.method, test.path)
+		if route == nil {
+			t.Errorf("%s %s: expected a route", test.method, test.path)
+			continue
+		}
+		eq(t, test.method+" "+test.path, route.Action, test.action)
+		eq(t, test.method+" "+test.path+" Allow", strings.Join(route.Allow, ", "), test.allow)
+	}
+	for _, path := range []string{"/no/such/path", "/favicon.ico"} {
+		if route := router.match("POST", path); route != nil {
+			t.Errorf("%s: expected no route, got %+v", path, route)
+		}
+	}
+}
+
+func TestRouterFilterMethodNotAllowed(t *testing.T) {
+	startFakeBookingApp()
+	for _, test := range []struct {
+		method string
+		status int
+	}{
+		{"POST", http.StatusMethodNotAllowed},
+		{"OPTIONS", http.StatusNoContent},
+	} {
+		req, _ := http.NewRequest(test.method, "/hotels/3/booking", nil)
+		resp := httptest.NewRecorder()
+		c := NewController(NewRequest(req), NewResponse(resp))
+		c.Params = &Params{}
+		RouterFilter(c, []Filter{func(c *Controller, fc []Filter) {
+			t.Errorf("Expected %s to be answered by the RouterFilter", test.method)
+		}})
+		c.Result.Apply(c.Request, c.Response)
+		eq(t, test.method+" status", resp.Code, test.status)
+		eq(t, test.method+" Allow", resp.Header().Get("Allow"), "GET, HEAD, OPTIONS")
+	}
+}
+
+func TestRouterAdd(t *testing.T) {
+	startFakeBookingApp()
+	defer func() {
+		MainRouter.added = nil
+		MainRouter.Refresh()
+	}()
+
+	// Invalid routes are refused right away.
+	if err := MainRouter.Add("FETCH", "/api/hotels", "Hotels.Index"); err == nil {
+		t.Error("Expected an error for an invalid method")
+	}
+	if err := MainRouter.Add("GET", "api/hotels", "Hotels.Index"); err == nil {
+		t.Error("Expected an error for a relative path")
+	}
+	if err := MainRouter.Add("GET", "/api/hotels", "Hotels.Nope"); err == nil {
+		t.Error("Expected an error for an unknown action")
+	}
+	if route := MainRouter.match("GET", "/api/hotels"); route.ControllerName == "Hotels" {
+		t.Errorf("Expected the invalid route to be dropped, got %+v", route)
+	}
+
+	if err := MainRouter.Add("GET", "/api/hotels/:id<[0-9]+>", "Hotels.Show"); err != nil {
+		t.Fatal(err)
+	}
+	pprof := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprint(w, "profile ", r.URL.Path)
+	})
+	if err := MainRouter.Handle("/debug/pprof/", pprof); err != nil {
+		t.Fatal(err)
+	}
+
+	// The added routes take precedence, and survive a refresh.
+	for i := 0; i < 2; i++ {
+		route := MainRouter.match("GET", "/api/hotels/3")
+		if route == nil || route.MethodName != "Show" || url.Values(route.Params).Get("id") != "3" {
+			t.Errorf("Expected /api/hotels/3 to be routed to Hotels.Show, got %+v", route)
+		}
+		eq(t, "Reverse", MainRouter.Reverse("Hotels.Show", map[string]string{"id": "3"}).Url, "/api/hotels/3")
+		if err := MainRouter.Refresh(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, path := range []string{"/debug/pprof", "/debug/pprof/heap"} {
+		req, _ := http.NewRequest("POST", path, nil)
+		resp := httptest.NewRecorder()
+		c := NewController(NewRequest(req), NewResponse(resp))
+		c.Params = &Params{}
+		RouterFilter(c, []Filter{func(c *Controller, fc []Filter) {
+			t.Errorf("Expected %s to be served by the handler", path)
+		}})
+		c.Result.Apply(c.Request, c.Response)
+		eq(t, path+" status", c.Response.Status, http.StatusTeapot)
+		eq(t, path+" body", resp.Body.String(), "profile "+path)
+	}
+}
+
+func TestHandleRequestBody(t *testing.T) {
+	startFakeBookingApp()
+	defer func() {
+		MainRouter.added = nil
+		MainRouter.Refresh()
+	}()
+
+	var body, method string
+	hook := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body, method = string(b), r.Method
+	})
+	if err := MainRouter.Handle("/hook/", hook); err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("POST", "/hook/github", strings.NewReader("a=1&b=2&_method=put"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c := NewController(NewRequest(req), NewResponse(httptest.NewRecorder()))
+	c.Params = &Params{}
+	RouterFilter(c, []Filter{func(c *Controller, fc []Filter) {
+		t.Error("Expected the request to be served by the handler")
+	}})
+	c.Result.Apply(c.Request, c.Response)
+	eq(t, "body", body, "a=1&b=2&_method=put")
+	eq(t, "method", method, "POST")
+}
+
+func TestRouterAddShadowsFile(t *testing.T) {
+	startFakeBookingApp()
+	defer func() {
+		MainRouter.added = nil
+		MainRouter.Refresh()
+	}()
+
+	// GET /hotels is in the routes file as Hotels.Index.
+	if err := MainRouter.Add("GET", "/hotels", "Hotels.Show"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MainRouter.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{"GET", "HEAD"} {
+		if route := MainRouter.match(method, "/hotels"); route == nil || route.MethodName != "Show" {
+			t.Errorf("Expected %s /hotels to be routed to Hotels.Show, got %+v", method, route)
+		}
+	}
+	// By the catch-all route.
+	eq(t, "Reverse of the shadowed route", MainRouter.Reverse("Hotels.Index", map[string]string{}).Url, "/Hotels/Index")
+
+	// Two added routes with the same path conflict, and the error names them.
+	err := MainRouter.Add("GET", "/hotels", "Hotels.Index")
+	if err == nil || !strings.Contains(err.Error(), "GET /hotels (added in code)") {
+		t.Errorf("Expected a conflict naming the added route, got %v", err)
+	}
+}
+
+func TestReverseUrlConstraint(t *testing.T) {
+	startFakeBookingApp()
+	defer MainRouter.Refresh()
+
+	MainRouter.Routes, _ = parseRoutes("", "", "GET /hotels/:id<[0-9]+> Hotels.Show", true)
+	MainRouter.updateTree()
+
+	url, err := ReverseUrl("Hotels.Show", 3)
+	eq(t, "url", string(url), "/hotels/3")
+	eq(t, "url error", err, nil)
+	if _, err = ReverseUrl("Hotels.Show", "abc"); err == nil {
+		t.Error("Expected an error for an argument violating the constraint")
+	}
+}
+
+func TestAbsUrl(t *testing.T) {
+	startFakeBookingApp()
+	defer MainRouter.Refresh()
+	oldAppUrl, oldAppRoot := AppUrl, AppRoot
+	defer func() { AppUrl, AppRoot = oldAppUrl, oldAppRoot }()
+	AppUrl, AppRoot = "https://example.com", "/app"
+
+	MainRouter.Routes, _ = parseRoutes("", "", `
+GET /hotels          Hotels.Index
+WS  /hotels/:id/live Hotels.Show
+`, true)
+	MainRouter.updateTree()
+
+	absUrl := TemplateFuncs["absUrl"].(func(...interface{}) (template.URL, error))
+	url, err := absUrl("Hotels.Index")
+	eq(t, "url", string(url), "https://example.com/app/hotels")
+	eq(t, "url error", err, nil)
+	url, err = absUrl("Hotels.Show", 3)
+	eq(t, "ws url", string(url), "wss://example.com/app/hotels/3/live")
+	eq(t, "ws url error", err, nil)
+	url, err = absUrl("Root")
+	eq(t, "root url", string(url), "https://example.com/app")
+	eq(t, "root url error", err, nil)
+	if _, err = absUrl("Hotels.Missing"); err == nil {
+		t.Error("Expected an error for an unknown action")
+	}
+}
+
+const NAMED_ROUTES = `
+GET   /v1/hotels/:id             Hotels.Show                 as v1.hotel
+GET   /v2/hotels/:id<[0-9]+>     Hotels.Show                 as v2.hotel
+GET   /public/*filepath          Static.Serve("public")      as public
+`
+
+func TestReverseNamed(t *testing.T) {
+	startFakeBookingApp()
+	defer MainRouter.Refresh()
+
+	var err *Error
+	MainRouter.Routes, err = parseRoutes("", "", NAMED_ROUTES, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = MainRouter.updateTree(); err != nil {
+		t.Fatal(err)
+	}
+
+	eq(t, "Reverse", MainRouter.Reverse("Hotels.Show", map[string]string{"id": "3"}).Url, "/v1/hotels/3")
+	eq(t, "v2.hotel", MainRouter.ReverseNamed("v2.hotel", map[string]string{"id": "3"}).Url, "/v2/hotels/3")
+	eq(t, "v2.hotel violated", MainRouter.ReverseNamed("v2.hotel", map[string]string{"id": "x"}) == nil, true)
+	eq(t, "public", MainRouter.ReverseNamed("public", map[string]string{"filepath": "css/app.css"}).Url, "/public/css/app.css")
+	eq(t, "unknown", MainRouter.ReverseNamed("v3.hotel", map[string]string{}) == nil, true)
+
+	url, urlErr := ReverseNamedUrl("v2.hotel", 3)
+	eq(t, "urlFor", string(url), "/v2/hotels/3")
+	eq(t, "urlFor error", urlErr, nil)
+	if _, urlErr = ReverseNamedUrl("v3.hotel", 3); urlErr == nil {
+		t.Error("Expected an error for an unknown route name")
+	}
+
+	// Names are unique.
+	MainRouter.Routes, _ = parseRoutes("", "", NAMED_ROUTES+"GET /hotels Hotels.Index as public", false)
+	if MainRouter.updateTree() == nil {
+		t.Error("Expected a duplicate route name error")
+	}
+}
+
+func TestReverseNamedWithoutController(t *testing.T) {
+	startFakeBookingApp()
+	defer MainRouter.Refresh()
+
+	var err *Error
+	MainRouter.Routes, err = parseRoutes("", "", `
+GET   /api/:controller/:action   :controller.:action   as api
+GET   /gone/:id                  404                   as gone
+`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = MainRouter.updateTree(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, urlErr := ReverseNamedUrl("api", "hotels", "show", 3); urlErr == nil {
+		t.Error("Expected an error for too many arguments")
 	}
+	url, urlErr := ReverseNamedUrl("api", "hotels", "show")
+	eq(t, "wildcard action", string(url), "/api/hotels/show")
+	eq(t, "wildcard action error", urlErr, nil)
+	url, urlErr = ReverseNamedUrl("gone", 3)
+	eq(t, "no controller", string(url), "/gone/3")
+	eq(t, "no controller error", urlErr, nil)
 }
 
 func BenchmarkRouter(b *testing.B) {
@@ -1,22 +1,43 @@
 package revel
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
-	"strconv"
+	"os"
+	"os/signal"
+	"path"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/websocket"
 )
 
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "http.maxrequestsize", Type: ConfigInt, Default: "0", Description: "The maximum size of request bodies in bytes, or 0 for no limit"},
+		ConfigOption{Name: "http.timeout.read.*.*", Type: ConfigDuration, Description: "The read timeout of a Controller.Action"},
+		ConfigOption{Name: "http.timeout.write.*.*", Type: ConfigDuration, Description: "The write timeout of a Controller.Action"},
+		ConfigOption{Name: "http.shutdown.timeout", Type: ConfigDuration, Default: "30s", Description: "How long to wait for in-flight requests on shutdown"},
+		ConfigOption{Name: "http.shutdown.delay", Type: ConfigDuration, Default: "0", Description: "How long to keep serving, reporting not ready, before draining on shutdown"},
+		ConfigOption{Name: "watch", Type: ConfigBool, Default: "true", Description: "Whether to watch the source files for changes"},
+		ConfigOption{Name: "watch.templates", Type: ConfigBool, Default: "true", Description: "Whether to reload the templates when they change"},
+		ConfigOption{Name: "watch.config", Type: ConfigBool, Default: "true", Description: "Whether to reload app.conf when it changes, in dev mode"},
+	)
+}
+
 var (
 	MainRouter         *Router
 	MainTemplateLoader *TemplateLoader
 	MainWatcher        *Watcher
-	Server             *http.Server
+	Server             *http.Server   // The server of the first listener, once Run has started
+	Servers            []*http.Server // The servers of every listener (see http.listeners)
+
+	serverInitialized bool // true once initServer has completed.
 )
 
 // This method handles all requests.  It dispatches to handleInternal after
@@ -26,6 +47,10 @@ func handle(w http.ResponseWriter, r *http.Request) {
 		r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 	}
 
+	if len(actionTimeouts) > 0 {
+		setActionDeadlines(w, r)
+	}
+
 	upgrade := r.Header.Get("Upgrade")
 	if upgrade == "websocket" || upgrade == "Websocket" {
 		websocket.Handler(func(ws *websocket.Conn) {
@@ -39,6 +64,79 @@ func handle(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// actionTimeout holds the timeouts of an action, configured with
+// http.timeout.read.<Controller.Action> and http.timeout.write.<Controller.Action>.
+type actionTimeout struct {
+	read, write time.Duration
+}
+
+// actionTimeouts maps lower case "controller.action" to its timeouts.
+var actionTimeouts map[string]actionTimeout
+
+// loadActionTimeouts reads the per-action timeouts from Config.
+func loadActionTimeouts() *Error {
+	timeouts := make(map[string]actionTimeout)
+	for _, kind := range []string{"read", "write"} {
+		prefix := "http.timeout." + kind + "."
+		for _, option := range Config.Options(prefix) {
+			action := strings.ToLower(option[len(prefix):])
+			if strings.Count(action, ".") != 1 {
+				continue
+			}
+			d, err := durationOption(option, 0)
+			if err != nil {
+				return err
+			}
+			timeout := timeouts[action]
+			if kind == "read" {
+				timeout.read = d
+			} else {
+				timeout.write = d
+			}
+			timeouts[action] = timeout
+		}
+	}
+	actionTimeouts = timeouts
+	return nil
+}
+
+// setActionDeadlines replaces the read and write deadlines of the connection
+// when the requested action has timeouts of its own (e.g. for uploads).  The
+// route is looked up without parsing the request body, so that the new read
+// deadline applies to it.
+func setActionDeadlines(w http.ResponseWriter, r *http.Request) {
+	if MainRouter == nil {
+		return
+	}
+	method := r.Method
+	if override := r.Header.Get("X-HTTP-Method-Override"); override != "" && method == "POST" {
+		method = override
+	}
+	match := MainRouter.match(method, r.URL.Path)
+	if match == nil || match.ControllerName == "" {
+		return
+	}
+	timeout, ok := actionTimeouts[strings.ToLower(match.ControllerName+"."+match.MethodName)]
+	if !ok {
+		return
+	}
+
+	var (
+		controller = http.NewResponseController(w)
+		now        = time.Now()
+	)
+	if timeout.read != 0 {
+		if err := controller.SetReadDeadline(now.Add(timeout.read)); err != nil {
+			TRACE.Println("Failed to set the read deadline:", err)
+		}
+	}
+	if timeout.write != 0 {
+		if err := controller.SetWriteDeadline(now.Add(timeout.write)); err != nil {
+			TRACE.Println("Failed to set the write deadline:", err)
+		}
+	}
+}
+
 func handleInternal(w http.ResponseWriter, r *http.Request, ws *websocket.Conn) {
 	var (
 		req  = NewRequest(r)
@@ -46,6 +144,7 @@ func handleInternal(w http.ResponseWriter, r *http.Request, ws *websocket.Conn)
 		c    = NewController(req, resp)
 	)
 	req.Websocket = ws
+	c.Log = RequestLog(req)
 
 	Filters[0](c, Filters[1:])
 	if c.Result != nil {
@@ -59,45 +158,76 @@ func handleInternal(w http.ResponseWriter, r *http.Request, ws *websocket.Conn)
 	}
 }
 
-// Run the server.
-// This is called from the generated main file.
-// If port is non-zero, use that.  Else, read the port from app.conf.
-func Run(port int) {
-	address := HttpAddr
-	if port == 0 {
-		port = HttpPort
+// NewHandler prepares the application to serve requests and returns an
+// http.Handler that dispatches them through the Filters chain, in the same way
+// as the server started by Run.
+//
+// It is meant for mounting a Revel application inside an existing program,
+// which owns the listener(s) and the http.Server.  Init must have been called
+// beforehand.  Failures during the startup hooks are returned rather than
+// terminating the process.
+//
+// Example:
+//
+//      revel.Init("prod", "corp/sample", "")
+//      handler, err := revel.NewHandler()
+//      if err != nil {
+//          log.Fatal(err)
+//      }
+//      mux := http.NewServeMux()
+//      mux.Handle("/", handler)
+//      mux.Handle("/rpc/", gatewayHandler)
+//      http.ListenAndServe(":8080", mux)
+//
+func NewHandler() (http.Handler, error) {
+	if !Initialized {
+		return nil, &Error{
+			Title:       "Revel has not been initialized",
+			Description: "revel.Init must be called before revel.NewHandler",
+		}
 	}
-
-	var network = "tcp"
-	var localAddress string
-
-	// If the port is zero, treat the address as a fully qualified local address.
-	// This address must be prefixed with the network type followed by a colon,
-	// e.g. unix:/tmp/app.socket or tcp6:::1 (equivalent to tcp6:0:0:0:0:0:0:0:1)
-	if port == 0 {
-		parts := strings.SplitN(address, ":", 2)
-		network = parts[0]
-		localAddress = parts[1]
-	} else {
-		localAddress = address + ":" + strconv.Itoa(port)
+	if err := initServer(); err != nil {
+		return nil, err
 	}
+	return http.HandlerFunc(handle), nil
+}
 
-	Server = &http.Server{
-		Addr:         localAddress,
-		Handler:      http.HandlerFunc(handle),
-		ReadTimeout:  time.Minute,
-		WriteTimeout: time.Minute,
+// initServer runs the startup hooks, loads the templates and sets up the
+// watcher.  It is shared by Run and NewHandler, and only does its work once.
+// A panic in a startup hook is returned as an error.
+func initServer() (err error) {
+	if serverInitialized {
+		return nil
 	}
 
+	defer func() {
+		if r := recover(); r != nil {
+			if revelError, ok := r.(*Error); ok {
+				err = revelError
+				return
+			}
+			err = &Error{
+				Title:       "Startup Error",
+				Description: fmt.Sprint(r),
+			}
+		}
+	}()
+
 	runStartupHooks()
 
 	// Load templates
 	MainTemplateLoader = NewTemplateLoader(TemplatePaths)
 	MainTemplateLoader.Refresh()
 
+	// In dev mode, serve the effective configuration.
+	if DevMode {
+		Filters = append([]Filter{ConfigPageFilter}, Filters...)
+	}
+
 	// The "watch" config variable can turn on and off all watching.
 	// (As a convenient way to control it all together.)
-	if Config.BoolDefault("watch", true) {
+	// There is nothing to watch when running from an embedded file system.
+	if SourceFS == nil && Config.BoolDefault("watch", true) {
 		MainWatcher = NewWatcher()
 		Filters = append([]Filter{WatchFilter}, Filters...)
 	}
@@ -108,26 +238,195 @@ func Run(port int) {
 		MainWatcher.Listen(MainTemplateLoader, MainTemplateLoader.paths...)
 	}
 
+	// In dev mode, reload app.conf when it changes.
+	if MainWatcher != nil && DevMode && Config.BoolDefault("watch.config", true) {
+		MainWatcher.Listen(Config, path.Join(BasePath, "conf"))
+	}
+
+	serverInitialized = true
+	return nil
+}
+
+// Run the server.
+// This is called from the generated main file.
+// If port is non-zero, use that.  Else, read the port from app.conf.
+//
+// The listening sockets may be passed by systemd socket activation
+// (LISTEN_FDS), matched to the configured listeners by name or address.  On
+// SIGUSR2, the server starts a new process of the program with its sockets,
+// and drains once the new process serves requests, for zero-downtime
+// restarts.
+//
+// Run terminates the process if the server can not be started.  Use RunE to
+// handle the failure instead.
+func Run(port int) {
+	if err := RunE(port); err != nil {
+		ERROR.Fatalln(err)
+	}
+}
+
+// setRunPort makes the default app.url use the port passed to Run, also once
+// the config is reloaded.  In dev mode, that port is the one the harness
+// proxies http.port to, so app.url stays on http.port.
+func setRunPort(port int) *Error {
+	if port == 0 || DevMode {
+		return nil
+	}
+	runPort = port
+	var err *Error
+	AppUrl, err = appUrl(port)
+	return err
+}
+
+// RunE runs the server like Run, but returns an *Error if the server could not
+// be started or stopped serving unexpectedly.  It returns nil once the server
+// has been shut down gracefully.
+func RunE(port int) error {
+	listeners, err := configuredListeners(port)
+	if err != nil {
+		return err
+	}
+
+	if err = setRunPort(port); err != nil {
+		return err
+	}
+
+	for _, l := range listeners {
+		if l.Ssl {
+			if l.tls, err = l.tlsConfig(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := initServer(); err != nil {
+		return err
+	}
+
+	// Use the sockets passed by systemd or by the previous process, if any.
+	inherited, parent, inheritErr := inheritedListeners()
+	if inheritErr != nil {
+		return &Error{
+			Title:       "Failed to use the inherited listeners",
+			Description: inheritErr.Error(),
+		}
+	}
+
+	// Open every listener before serving on any of them.
+	netListeners := make([]net.Listener, len(listeners))
+	for i, l := range listeners {
+		var netListener net.Listener
+		if inherited, netListener = takeInheritedListener(inherited, l, len(listeners) == 1); netListener != nil {
+			netListeners[i] = netListener
+			continue
+		}
+
+		netListener, err := net.Listen(l.Network, l.Address)
+		if err != nil {
+			for _, opened := range netListeners[:i] {
+				opened.Close()
+			}
+			return &Error{
+				Title:       "Failed to listen",
+				Description: err.Error(),
+			}
+		}
+		netListeners[i] = netListener
+	}
+	for _, unused := range inherited {
+		WARN.Println("Closing the inherited listener", unused.name, unused.listener.Addr(), ": no listener is configured for it")
+		unused.listener.Close()
+	}
+
 	go func() {
 		time.Sleep(100 * time.Millisecond)
-		fmt.Printf("Listening on %s...\n", localAddress)
+		for _, l := range listeners {
+			fmt.Printf("Listening on %s...\n", l.Address)
+		}
 	}()
 
-	if HttpSsl {
-		if network != "tcp" {
-			// This limitation is just to reduce complexity, since it is standard
-			// to terminate SSL upstream when using unix domain sockets.
-			ERROR.Fatalln("SSL is only supported for TCP sockets. Specify a port to listen on.")
+	Servers = make([]*http.Server, len(listeners))
+	serveErrors := make(chan error, len(listeners))
+	for i, l := range listeners {
+		Servers[i] = l.newServer(listeners)
+		go func(l *serverListener, server *http.Server, netListener net.Listener) {
+			serveErrors <- l.serve(server, netListener)
+		}(l, Servers[i], netListeners[i])
+	}
+	Server = Servers[0]
+	notifyReady(parent)
+
+	// Block until we are asked to stop, then drain the servers.
+	// On SIGUSR2, hand the listeners off to a new process, which asks this one
+	// to stop once it serves requests.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	if handoffSignal != nil {
+		signal.Notify(signals, handoffSignal)
+	}
+	defer signal.Stop(signals)
+	for stop := false; !stop; {
+		select {
+		case err := <-serveErrors:
+			for _, server := range Servers {
+				server.Close()
+			}
+			return &Error{
+				Title:       "Failed to serve",
+				Description: err.Error(),
+			}
+		case sig := <-signals:
+			if sig == handoffSignal {
+				if err := handOff(listeners, netListeners); err != nil {
+					ERROR.Println("Failed to hand the listeners off to a new process:", err)
+				}
+				continue
+			}
+			INFO.Println("Received", sig, "signal, shutting down")
+			stop = true
 		}
-		ERROR.Fatalln("Failed to listen:",
-			Server.ListenAndServeTLS(HttpSslCert, HttpSslKey))
-	} else {
-		listener, err := net.Listen(network, localAddress)
-		if err != nil {
-			ERROR.Fatalln("Failed to listen:", err)
+	}
+	shutdown()
+	return nil
+}
+
+// shutdown stops the servers from accepting new connections, waits for the
+// in-flight requests to complete (up to http.shutdown.timeout), and then runs
+// the shutdown hooks.
+func shutdown() {
+	timeout := 30 * time.Second
+	if timeoutStr, found := Config.String("http.shutdown.timeout"); found {
+		var err error
+		if timeout, err = time.ParseDuration(timeoutStr); err != nil {
+			ERROR.Println("Could not parse http.shutdown.timeout", timeoutStr, ":", err)
+			timeout = 30 * time.Second
 		}
-		ERROR.Fatalln("Failed to serve:", Server.Serve(listener))
 	}
+
+	// Report not ready, and keep serving while the load balancers notice.
+	shuttingDown.Store(true)
+	if delay, err := durationOption("http.shutdown.delay", 0); err != nil {
+		ERROR.Println(err.Description)
+	} else if delay > 0 {
+		INFO.Println("Waiting", delay, "before draining")
+		time.Sleep(delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, server := range Servers {
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				ERROR.Println("Failed to drain in-flight requests on", server.Addr, ":", err)
+			}
+		}(server)
+	}
+	wg.Wait()
+
+	runShutdownHooks()
 }
 
 func runStartupHooks() {
@@ -177,3 +476,76 @@ var startupHooks []func()
 func OnAppStart(f func()) {
 	startupHooks = append(startupHooks, f)
 }
+
+func runShutdownHooks() {
+	for i := len(shutdownHooks) - 1; i >= 0; i-- {
+		shutdownHooks[i]()
+	}
+}
+
+var shutdownHooks []func()
+
+// Register a function to be run at app shutdown.
+//
+// The hooks run after the server has stopped accepting connections and the
+// in-flight requests have been drained (or http.shutdown.timeout has passed),
+// in the reverse order of their registration.  You can think of it as a LIFO
+// stack, mirroring OnAppStart: resources opened first are closed last.
+//
+// Example:
+//
+//      // from: yourapp/app/init.go
+//      func init() {
+//          revel.OnAppStart(InitDB)
+//          revel.OnAppStop(CloseDB)
+//      }
+//
+// This can be useful when you need to close database pools, flush buffers or
+// deregister from a service discovery system before the process exits.
+//
+func OnAppStop(f func()) {
+	shutdownHooks = append(shutdownHooks, f)
+}
+
+func runConfigChangeHooks() (err *Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if revelError, ok := r.(*Error); ok {
+				err = revelError
+				return
+			}
+			err = &Error{
+				Title:       "Config Reload Error",
+				Description: fmt.Sprint(r),
+			}
+		}
+	}()
+
+	for _, hook := range configChangeHooks {
+		hook()
+	}
+	return nil
+}
+
+var configChangeHooks []func()
+
+// Register a function to be run when app.conf is reloaded, after the options
+// of Config are replaced.  This happens in dev mode, when the file is changed
+// while the app runs.  The package variables derived from app.conf by Init
+// (HttpPort, CookieDomain, ...) are left as they are, as requests may be
+// reading them.
+//
+// Like the startup hooks, they are run in the order they were registered, and
+// may panic to report an error, which is displayed on the next request.
+//
+// Example:
+//
+//      func init() {
+//          revel.OnConfigChange(func() {
+//              featureEnabled = revel.Config.BoolDefault("feature.enabled", false)
+//          })
+//      }
+//
+func OnConfigChange(f func()) {
+	configChangeHooks = append(configChangeHooks, f)
+}
@@ -7,6 +7,7 @@ import (
 	"path"
 	"strings"
 	"testing"
+	"time"
 )
 
 // This tries to benchmark the usual request-serving pipeline to get an overall
@@ -86,6 +87,38 @@ func TestFakeServer(t *testing.T) {
 	resp.Body = nil
 }
 
+// Test that the handler returned by NewHandler serves the app's actions.
+func TestNewHandler(t *testing.T) {
+	startFakeBookingApp()
+	defer func() { serverInitialized = false }()
+
+	handler, err := NewHandler()
+	if err != nil {
+		t.Fatal("Failed to create handler:", err)
+	}
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, plaintextRequest)
+	if resp.Body.String() != "Hello, World!" {
+		t.Errorf("Failed to find greeting in plaintext response:\n%s", resp.Body)
+	}
+}
+
+// Test that the shutdown hooks run in the reverse order of their registration.
+func TestOnAppStop(t *testing.T) {
+	defer func(hooks []func()) { shutdownHooks = hooks }(shutdownHooks)
+	shutdownHooks = nil
+
+	var order []string
+	OnAppStop(func() { order = append(order, "first") })
+	OnAppStop(func() { order = append(order, "second") })
+	runShutdownHooks()
+
+	if strings.Join(order, ",") != "second,first" {
+		t.Errorf("Expected shutdown hooks to run in reverse order, got %v", order)
+	}
+}
+
 func getFileSize(t *testing.T, name string) int64 {
 	fi, err := os.Stat(name)
 	if err != nil {
@@ -101,3 +134,50 @@ var (
 	jsonRequest, _      = http.NewRequest("GET", "/hotels/3/booking", nil)
 	plaintextRequest, _ = http.NewRequest("GET", "/hotels", nil)
 )
+
+// deadlineRecorder records the deadlines set through http.ResponseController.
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	read, write time.Time
+}
+
+func (r *deadlineRecorder) SetReadDeadline(t time.Time) error {
+	r.read = t
+	return nil
+}
+
+func (r *deadlineRecorder) SetWriteDeadline(t time.Time) error {
+	r.write = t
+	return nil
+}
+
+// Test that the per-action timeouts replace the connection deadlines.
+func TestActionTimeouts(t *testing.T) {
+	startFakeBookingApp()
+	Config.SetOption("http.timeout.read.Hotels.Show", "10m")
+	Config.SetOption("http.timeout.write.hotels.show", "5m")
+	defer func() {
+		Config.Raw().RemoveOption(Config.section, "http.timeout.read.Hotels.Show")
+		Config.Raw().RemoveOption(Config.section, "http.timeout.write.hotels.show")
+		loadActionTimeouts()
+	}()
+	if err := loadActionTimeouts(); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	resp := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handle(resp, showRequest)
+	if resp.read.Sub(start) < 10*time.Minute || resp.write.Sub(start) < 5*time.Minute {
+		t.Errorf("Expected deadlines in 10m and 5m, got %v and %v", resp.read.Sub(start), resp.write.Sub(start))
+	}
+	if !strings.Contains(resp.Body.String(), "300 Main St.") {
+		t.Errorf("Failed to find hotel address in action response:\n%s", resp.Body)
+	}
+
+	resp = &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handle(resp, jsonRequest)
+	if !resp.read.IsZero() || !resp.write.IsZero() {
+		t.Error("Expected no deadline for an action without timeouts")
+	}
+}
@@ -27,6 +27,10 @@ const (
 var expireAfterDuration time.Duration
 
 func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "session.expires", Default: "720h", Description: `The session duration, or "session" for a browser session`},
+	)
+
 	// Set expireAfterDuration, default to 30 days if no value in config
 	OnAppStart(func() {
 		var err error
@@ -4,8 +4,11 @@ import "github.com/revel/revel"
 
 func init() {
 	// Filters is the default set of global filters.
+	// Add revel.MetricsFilter after the PanicFilter to record request metrics.
 	revel.Filters = []revel.Filter{
+		revel.AccessLogFilter,         // Log the requests to log.access.output.
 		revel.PanicFilter,             // Recover from panics and display an error page instead.
+		revel.HealthFilter,            // Serve the health and readiness endpoints.
 		revel.RouterFilter,            // Use the routing table to select the right Action
 		revel.FilterConfiguringFilter, // A hook for adding or removing per-Action filters.
 		revel.ParamsFilter,            // Parse parameters into Controller.Params.
@@ -23,6 +26,10 @@ func init() {
 	// ( order dependent )
 	// revel.OnAppStart(InitDB)
 	// revel.OnAppStart(FillCache)
+
+	// register shutdown functions with OnAppStop
+	// ( run in reverse order of registration )
+	// revel.OnAppStop(CloseDB)
 }
 
 // TODO turn this into revel.HeaderFilter
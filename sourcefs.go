@@ -0,0 +1,131 @@
+package revel
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SourceFS, if set, is the file system from which Revel reads the views,
+// configuration, messages, routes and public files of the app and its modules,
+// instead of the disk.  It is typically an embed.FS, to ship the application
+// as a single binary.
+//
+// The file system must be laid out like the source tree of a packaged app,
+// with the app and Revel under their import paths, e.g.:
+//
+//     corp/sample/app/views/...
+//     corp/sample/conf/app.conf
+//     corp/sample/conf/routes
+//     corp/sample/messages/...
+//     corp/sample/public/...
+//     github.com/revel/revel/conf/mime-types.conf
+//     github.com/revel/revel/templates/...
+//
+// While it is set, all of the *Path variables hold slash-separated paths
+// within the file system, and file watching is disabled.
+var SourceFS fs.FS
+
+// InitFS initializes Revel to run entirely from the given file system, in
+// packaged mode.  See SourceFS for the expected layout.
+//
+// Example:
+//
+//      //go:embed corp/sample github.com/revel/revel/conf github.com/revel/revel/templates
+//      var sources embed.FS
+//
+//      func main() {
+//          if err := revel.InitFS("prod", "corp/sample", sources); err != nil {
+//              log.Fatal(err)
+//          }
+//          revel.Run(0)
+//      }
+func InitFS(mode, importPath string, fsys fs.FS) error {
+	SourceFS = fsys
+	return InitE(mode, importPath, ".")
+}
+
+// OpenFile opens the named file of the app (e.g. path.Join(BasePath, "public",
+// "js/app.js")), from SourceFS if it is set, or else from the disk.  The
+// returned file may be passed to Controller.RenderBinary.
+func OpenFile(name string) (fs.File, error) {
+	if SourceFS != nil {
+		return SourceFS.Open(fsName(name))
+	}
+	return os.Open(name)
+}
+
+// readSourceFile reads the named file, from SourceFS if set.
+func readSourceFile(name string) ([]byte, error) {
+	if SourceFS != nil {
+		return fs.ReadFile(SourceFS, fsName(name))
+	}
+	return ioutil.ReadFile(name)
+}
+
+// readSourceLines reads the lines of the named file, from SourceFS if set.
+func readSourceLines(name string) ([]string, error) {
+	bytes, err := readSourceFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(string(bytes), "\n"), nil
+}
+
+// sourceDirExists returns true if the given path is a directory, in SourceFS
+// if set.
+func sourceDirExists(name string) bool {
+	if SourceFS != nil {
+		fileInfo, err := fs.Stat(SourceFS, fsName(name))
+		return err == nil && fileInfo.IsDir()
+	}
+	return DirExists(name)
+}
+
+// sourceFileExists returns true if the given path is a regular file, in
+// SourceFS if set.
+func sourceFileExists(name string) bool {
+	if SourceFS != nil {
+		fileInfo, err := fs.Stat(SourceFS, fsName(name))
+		return err == nil && !fileInfo.IsDir()
+	}
+	fileInfo, err := os.Stat(name)
+	return err == nil && !fileInfo.IsDir()
+}
+
+// globSource returns the names of the files matching the pattern, in lexical
+// order, in SourceFS if set.
+func globSource(pattern string) ([]string, error) {
+	if SourceFS != nil {
+		return fs.Glob(SourceFS, fsName(pattern))
+	}
+	return filepath.Glob(pattern)
+}
+
+// walkSource walks the file tree rooted at root like filepath.Walk, in SourceFS
+// if set.
+func walkSource(root string, walkFn filepath.WalkFunc) error {
+	if SourceFS == nil {
+		return filepath.Walk(root, walkFn)
+	}
+	return fs.WalkDir(SourceFS, fsName(root), func(path string, d fs.DirEntry, err error) error {
+		var info os.FileInfo
+		if d != nil {
+			// Like filepath.Walk, report the error rather than a nil info.
+			var infoErr error
+			if info, infoErr = d.Info(); err == nil {
+				err = infoErr
+			}
+		}
+		return walkFn(path, info, err)
+	})
+}
+
+// fsName converts a path built with the *Path variables into a name valid for
+// an fs.FS, which does not accept "./" prefixes or OS separators.
+func fsName(name string) string {
+	name = filepath.ToSlash(filepath.Clean(name))
+	return strings.TrimPrefix(name, "/")
+}
@@ -0,0 +1,42 @@
+package revel
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Test that the booking app can be served entirely from an fs.FS.
+func TestInitFS(t *testing.T) {
+	startFakeBookingApp()
+	defer startFakeBookingApp()
+	defer func() { SourceFS = nil }()
+
+	srcPath, _, err := findSrcPaths(REVEL_IMPORT_PATH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := InitFS("prod", "github.com/revel/revel/testdata", os.DirFS(srcPath)); err != nil {
+		t.Fatal("Failed to initialize from a file system:", err)
+	}
+	eq(t, "BasePath", BasePath, "github.com/revel/revel/testdata")
+
+	MainTemplateLoader = NewTemplateLoader(TemplatePaths)
+	if err := MainTemplateLoader.Refresh(); err != nil {
+		t.Fatal("Failed to load templates:", err)
+	}
+	runStartupHooks()
+
+	resp := httptest.NewRecorder()
+	handle(resp, showRequest)
+	if !strings.Contains(resp.Body.String(), "300 Main St.") {
+		t.Errorf("Failed to find hotel address in action response:\n%s", resp.Body)
+	}
+
+	resp = httptest.NewRecorder()
+	handle(resp, staticRequest)
+	if !strings.Contains(resp.Body.String(), "Test file") {
+		t.Errorf("Failed to serve the public file from the file system:\n%s", resp.Body)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"html"
 	"html/template"
 	"io"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -47,7 +46,15 @@ var whiteSpacePattern = regexp.MustCompile(`\s+`)
 var (
 	// The functions available for use in the templates.
 	TemplateFuncs = map[string]interface{}{
-		"url": ReverseUrl,
+		"url":    ReverseUrl,
+		"urlFor": ReverseNamedUrl,
+		"absUrl": func(args ...interface{}) (template.URL, error) {
+			definition, err := reverseAction(args...)
+			if err != nil {
+				return "", err
+			}
+			return template.URL(definition.AbsoluteUrl()), nil
+		},
 		"set": func(renderArgs map[string]interface{}, key string, value interface{}) template.JS {
 			renderArgs[key] = value
 			return template.JS("")
@@ -307,7 +314,10 @@ func (loader *TemplateLoader) Refresh() *Error {
 		// Handling symlinked directories
 		var fullSrcDir string
 		f, err := os.Lstat(basePath)
-		if err == nil && f.Mode()&os.ModeSymlink == os.ModeSymlink {
+		if SourceFS != nil {
+			// There are no symlinks within an embedded file system.
+			fullSrcDir = fsName(basePath)
+		} else if err == nil && f.Mode()&os.ModeSymlink == os.ModeSymlink {
 			fullSrcDir, err = filepath.EvalSymlinks(basePath)
 			if err != nil {
 				panic(err)
@@ -325,7 +335,7 @@ func (loader *TemplateLoader) Refresh() *Error {
 
 			// is it a symlinked template?
 			link, err := os.Lstat(path)
-			if err == nil && link.Mode()&os.ModeSymlink == os.ModeSymlink {
+			if SourceFS == nil && err == nil && link.Mode()&os.ModeSymlink == os.ModeSymlink {
 				TRACE.Println("symlink template:", path)
 				// lookup the actual target & check for goodness
 				targetPath, err := filepath.EvalSymlinks(path)
@@ -383,7 +393,7 @@ func (loader *TemplateLoader) Refresh() *Error {
 
 				// Load the file if we haven't already
 				if fileStr == "" {
-					fileBytes, err := ioutil.ReadFile(path)
+					fileBytes, err := readSourceFile(path)
 					if err != nil {
 						ERROR.Println("Failed reading file:", path)
 						return nil
@@ -478,7 +488,7 @@ func (loader *TemplateLoader) Refresh() *Error {
 			return nil
 		}
 
-		funcErr := filepath.Walk(fullSrcDir, templateWalker)
+		funcErr := walkSource(fullSrcDir, templateWalker)
 
 		// If there was an error with the Funcs, set it and return immediately.
 		if funcErr != nil {
@@ -561,7 +571,7 @@ func (gotmpl GoTemplate) Render(wr io.Writer, arg interface{}) error {
 }
 
 func (gotmpl GoTemplate) Content() []string {
-	content, _ := ReadLines(gotmpl.loader.templatePaths[gotmpl.Name()])
+	content, _ := readSourceLines(gotmpl.loader.templatePaths[gotmpl.Name()])
 	return content
 }
 
@@ -572,37 +582,100 @@ func (gotmpl GoTemplate) Content() []string {
 // Return a url capable of invoking a given controller method:
 // "Application.ShowApp 123" => "/app/123"
 func ReverseUrl(args ...interface{}) (template.URL, error) {
+	definition, err := reverseAction(args...)
+	if err != nil {
+		return "", err
+	}
+	return template.URL(definition.Url), nil
+}
+
+// reverseAction returns the route definition of the action given by the
+// arguments of ReverseUrl.  "Root" is the AppRoot.
+func reverseAction(args ...interface{}) (*ActionDefinition, error) {
 	if len(args) == 0 {
-		return "", fmt.Errorf("no arguments provided to reverse route")
+		return nil, fmt.Errorf("no arguments provided to reverse route")
 	}
 
 	action := args[0].(string)
 	if action == "Root" {
-		return template.URL(AppRoot), nil
+		return &ActionDefinition{Url: AppRoot, Action: action}, nil
 	}
 	actionSplit := strings.Split(action, ".")
 	if len(actionSplit) != 2 {
-		return "", fmt.Errorf("reversing '%s', expected 'Controller.Action'", action)
+		return nil, fmt.Errorf("reversing '%s', expected 'Controller.Action'", action)
 	}
 
 	// Look up the types.
-	var c Controller
-	if err := c.SetAction(actionSplit[0], actionSplit[1]); err != nil {
-		return "", fmt.Errorf("reversing %s: %s", action, err)
+	_, methodType, err := findAction(actionSplit[0], actionSplit[1])
+	if err != nil {
+		return nil, fmt.Errorf("reversing %s: %s", action, err)
 	}
 
-	if len(c.MethodType.Args) < len(args)-1 {
-		return "", fmt.Errorf("reversing %s: route defines %d args, but received %d",
-			action, len(c.MethodType.Args), len(args)-1)
+	if len(methodType.Args) < len(args)-1 {
+		return nil, fmt.Errorf("reversing %s: route defines %d args, but received %d",
+			action, len(methodType.Args), len(args)-1)
 	}
 
 	// Unbind the arguments.
 	argsByName := make(map[string]string)
 	for i, argValue := range args[1:] {
-		Unbind(argsByName, c.MethodType.Args[i].Name, argValue)
+		Unbind(argsByName, methodType.Args[i].Name, argValue)
 	}
 
-	return template.URL(MainRouter.Reverse(args[0].(string), argsByName).Url), nil
+	definition := MainRouter.Reverse(action, argsByName)
+	if definition == nil {
+		return nil, fmt.Errorf("reversing %s: no route for the arguments %v", action, args[1:])
+	}
+	return definition, nil
+}
+
+// ReverseNamedUrl returns the URL of the named route, given the arguments of
+// its action in order, like ReverseUrl, e.g. {{urlFor "v2.user" .user.Id}}
+func ReverseNamedUrl(args ...interface{}) (template.URL, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("no arguments provided to reverse route")
+	}
+
+	name, _ := args[0].(string)
+	route := MainRouter.namedRoute(name)
+	if route == nil {
+		return "", fmt.Errorf("reversing route %v: no route with that name", args[0])
+	}
+
+	// The arguments are those of the action or, for a route without a
+	// controller method (e.g. with a wildcard action or an http.Handler),
+	// its path parameters.
+	var argNames []string
+	if route.ControllerName == "" || route.MethodName == "" ||
+		route.ControllerName[0] == ':' || route.MethodName[0] == ':' {
+		argNames = route.pathArgNames()
+	} else {
+		// Look up the types.
+		_, methodType, err := findAction(route.ControllerName, route.MethodName)
+		if err != nil {
+			return "", fmt.Errorf("reversing route %s: %s", name, err)
+		}
+		for _, arg := range methodType.Args {
+			argNames = append(argNames, arg.Name)
+		}
+	}
+
+	if len(argNames) < len(args)-1 {
+		return "", fmt.Errorf("reversing route %s: %s defines %d args, but received %d",
+			name, route.Action, len(argNames), len(args)-1)
+	}
+
+	// Unbind the arguments.
+	argsByName := make(map[string]string)
+	for i, argValue := range args[1:] {
+		Unbind(argsByName, argNames[i], argValue)
+	}
+
+	definition := MainRouter.ReverseNamed(name, argsByName)
+	if definition == nil {
+		return "", fmt.Errorf("reversing route %s: invalid arguments %v", name, args[1:])
+	}
+	return template.URL(definition.Url), nil
 }
 
 func Slug(text string) string {
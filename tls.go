@@ -0,0 +1,198 @@
+package revel
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "http.ssl.minversion", Default: "1.2", Description: "The minimum TLS version: 1.0, 1.1, 1.2 or 1.3"},
+		ConfigOption{Name: "http.ssl.ciphers", Type: ConfigList, Description: "The TLS 1.0-1.2 cipher suites, as named by crypto/tls"},
+		ConfigOption{Name: "http.ssl.clientca", Description: "The CA bundle used to verify client certificates"},
+		ConfigOption{Name: "http.ssl.clientauth", Default: "none", Description: "none, request, require, verify or require-verify (the default with a client CA)"},
+		ConfigOption{Name: "http.ssl.reload", Type: ConfigDuration, Default: "10s", Description: "How often to check the certificate files for changes, or 0 to never reload them"},
+		ConfigOption{Name: "http.listener.*.ssl.minversion", Description: "Overrides http.ssl.minversion for a listener"},
+		ConfigOption{Name: "http.listener.*.ssl.ciphers", Type: ConfigList, Description: "Overrides http.ssl.ciphers for a listener"},
+		ConfigOption{Name: "http.listener.*.ssl.clientca", Description: "Overrides http.ssl.clientca for a listener"},
+		ConfigOption{Name: "http.listener.*.ssl.clientauth", Description: "Overrides http.ssl.clientauth for a listener"},
+		ConfigOption{Name: "http.listener.*.ssl.reload", Type: ConfigDuration, Description: "Overrides http.ssl.reload for a listener"},
+	)
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsClientAuthTypes = map[string]tls.ClientAuthType{
+	"none":           tls.NoClientCert,
+	"request":        tls.RequestClientCert,
+	"require":        tls.RequireAnyClientCert,
+	"verify":         tls.VerifyClientCertIfGiven,
+	"require-verify": tls.RequireAndVerifyClientCert,
+}
+
+// tlsConfig returns the TLS configuration of an SSL listener, built from the
+// http.ssl.* options:
+//
+//      http.ssl.minversion = 1.2          # 1.0, 1.1, 1.2 or 1.3
+//      http.ssl.ciphers = TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, ...
+//      http.ssl.clientca = /path/to/ca.pem
+//      http.ssl.clientauth = require-verify
+//      http.ssl.reload = 10s              # how often to check for new cert/key files
+//
+// A named listener may override any of them with http.listener.<name>.ssl.*.
+//
+// The ciphers are the names listed by crypto/tls, and only apply up to TLS 1.2.
+// The client authentication is one of none, request, require, verify (if a
+// certificate is given) and require-verify, the default when a client CA is
+// configured.  The certificate and key files are reloaded when they change, so
+// that rotated certificates need no restart.
+func (l *serverListener) tlsConfig() (*tls.Config, *Error) {
+	invalid := func(option, description string) *Error {
+		return &Error{
+			Title:       "Invalid " + l.sslOptionName(option),
+			Description: description,
+		}
+	}
+
+	minVersion, ok := tlsVersions[l.sslOption("minversion", "1.2")]
+	if !ok {
+		return nil, invalid("minversion", "Expected 1.0, 1.1, 1.2 or 1.3, got: "+l.sslOption("minversion", ""))
+	}
+	config := &tls.Config{MinVersion: minVersion}
+
+	if ciphers := l.sslOption("ciphers", ""); ciphers != "" {
+		suites := make(map[string]uint16)
+		for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			suites[suite.Name] = suite.ID
+		}
+		for _, name := range strings.Split(ciphers, ",") {
+			id, ok := suites[strings.TrimSpace(name)]
+			if !ok {
+				return nil, invalid("ciphers", "Unknown cipher suite: "+name)
+			}
+			config.CipherSuites = append(config.CipherSuites, id)
+		}
+	}
+
+	clientAuth := "none"
+	if clientCA := l.sslOption("clientca", ""); clientCA != "" {
+		pem, err := os.ReadFile(clientCA)
+		if err != nil {
+			return nil, invalid("clientca", err.Error())
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, invalid("clientca", "No PEM certificate found in "+clientCA)
+		}
+		clientAuth = "require-verify"
+	}
+	clientAuth = l.sslOption("clientauth", clientAuth)
+	if config.ClientAuth, ok = tlsClientAuthTypes[clientAuth]; !ok {
+		return nil, invalid("clientauth", "Expected none, request, require, verify or require-verify, got: "+clientAuth)
+	}
+	if config.ClientAuth >= tls.VerifyClientCertIfGiven && config.ClientCAs == nil {
+		return nil, invalid("clientauth", "Verifying client certificates requires "+l.sslOptionName("clientca"))
+	}
+
+	interval, err := time.ParseDuration(l.sslOption("reload", "10s"))
+	if err != nil {
+		return nil, invalid("reload", "Expected a duration (e.g. 10s), got: "+l.sslOption("reload", ""))
+	}
+	reloader, err := newCertReloader(l.SslCert, l.SslKey, interval)
+	if err != nil {
+		return nil, &Error{
+			Title:       "Failed to load the SSL certificate",
+			Path:        l.SslCert,
+			Description: err.Error(),
+		}
+	}
+	config.GetCertificate = reloader.GetCertificate
+	return config, nil
+}
+
+// sslOption returns the value of http.listener.<name>.ssl.<option> for a named
+// listener, falling back to http.ssl.<option>.
+func (l *serverListener) sslOption(option, dfault string) string {
+	if l.Name != "" {
+		if value, found := Config.String(l.sslOptionName(option)); found {
+			return value
+		}
+	}
+	return Config.StringDefault("http.ssl."+option, dfault)
+}
+
+func (l *serverListener) sslOptionName(option string) string {
+	if l.Name != "" {
+		return "http.listener." + l.Name + ".ssl." + option
+	}
+	return "http.ssl." + option
+}
+
+// certReloader serves a certificate and key pair loaded from files, reloading
+// them when the files are modified.
+type certReloader struct {
+	certFile, keyFile string
+	interval          time.Duration // How often to check the files, or 0 to never reload
+
+	mutex   sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time // The latest modification time of the files loaded
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is a tls.Config.GetCertificate, returning the latest valid
+// certificate.  A certificate that fails to load (e.g. because only one of the
+// files has been replaced yet) is retried at the next check.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.interval > 0 && time.Since(r.checked) >= r.interval {
+		r.checked = time.Now()
+		if r.latestModTime().After(r.modTime) {
+			if err := r.load(); err != nil {
+				ERROR.Println("Failed to reload the SSL certificate", r.certFile, ":", err)
+			} else {
+				INFO.Println("Reloaded the SSL certificate", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *certReloader) load() error {
+	modTime := r.latestModTime()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert, r.modTime, r.checked = &cert, modTime, time.Now()
+	return nil
+}
+
+func (r *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		if fileInfo, err := os.Stat(name); err == nil && fileInfo.ModTime().After(latest) {
+			latest = fileInfo.ModTime()
+		}
+	}
+	return latest
+}
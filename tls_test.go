@@ -0,0 +1,185 @@
+package revel
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a certificate for commonName and its key as PEM files,
+// signed by parent (or self-signed if nil), and returns them.
+func writeTestCert(t *testing.T, dir, commonName string, parent *tls.Certificate) (tls.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, commonName+".pem"), filepath.Join(dir, commonName+".key")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := os.WriteFile(certFile, certPem, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert.Leaf, _ = x509.ParseCertificate(der)
+	return cert, certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+
+	dir := t.TempDir()
+	ca, caFile, _ := writeTestCert(t, dir, "ca", nil)
+	_, certFile, keyFile := writeTestCert(t, dir, "server", &ca)
+
+	Config = NewEmptyConfig()
+	Config.SetSection("prod")
+	Config.SetOption("http.ssl.minversion", "1.3")
+	Config.SetOption("http.ssl.clientca", caFile)
+	Config.SetOption("http.listener.public.ssl.clientauth", "none")
+	Config.SetOption("http.listener.public.ssl.ciphers", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256")
+
+	l := &serverListener{Name: "internal", Ssl: true, SslCert: certFile, SslKey: keyFile}
+	config, err := l.tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS13 || config.ClientAuth != tls.RequireAndVerifyClientCert || config.ClientCAs == nil {
+		t.Errorf("Unexpected TLS config for the internal listener: %+v", config)
+	}
+
+	l = &serverListener{Name: "public", Ssl: true, SslCert: certFile, SslKey: keyFile}
+	if config, err = l.tlsConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if config.ClientAuth != tls.NoClientCert || len(config.CipherSuites) != 1 ||
+		config.CipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("Unexpected TLS config for the public listener: %+v", config)
+	}
+
+	Config.SetOption("http.ssl.minversion", "1.4")
+	if _, err = l.tlsConfig(); err == nil {
+		t.Error("Expected an error for an unknown TLS version")
+	}
+}
+
+// Test that a client certificate is verified and exposed on the Request.
+func TestTLSClientCertificate(t *testing.T) {
+	oldConfig := Config
+	defer func() { Config = oldConfig }()
+
+	dir := t.TempDir()
+	ca, caFile, _ := writeTestCert(t, dir, "ca", nil)
+	_, certFile, keyFile := writeTestCert(t, dir, "server", &ca)
+	client, _, _ := writeTestCert(t, dir, "client", &ca)
+
+	Config = NewEmptyConfig()
+	Config.SetOption("http.ssl.clientca", caFile)
+
+	l := &serverListener{Network: "tcp", Address: "127.0.0.1:0", Ssl: true, SslCert: certFile, SslKey: keyFile}
+	var err *Error
+	if l.tls, err = l.tlsConfig(); err != nil {
+		t.Fatal(err)
+	}
+	netListener, listenErr := net.Listen(l.Network, l.Address)
+	if listenErr != nil {
+		t.Fatal(listenErr)
+	}
+	server := l.newServer(nil)
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cert := NewRequest(r).ClientCertificate(); cert != nil {
+			io.WriteString(w, cert.Subject.CommonName)
+		}
+	})
+	go l.serve(server, netListener)
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{client},
+	}}}
+	resp, getErr := httpClient.Get("https://" + netListener.Addr().String() + "/")
+	if getErr != nil {
+		t.Fatal(getErr)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "client" {
+		t.Errorf("Expected the client certificate CN, got %q", body)
+	}
+
+	// Without a certificate, the handshake fails.
+	httpClient = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if resp, getErr = httpClient.Get("https://" + netListener.Addr().String() + "/"); getErr == nil {
+		resp.Body.Close()
+		t.Error("Expected the request without a client certificate to fail")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	first, certFile, keyFile := writeTestCert(t, dir, "server", nil)
+
+	reloader, err := newCertReloader(certFile, keyFile, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, _ := reloader.GetCertificate(nil); string(cert.Certificate[0]) != string(first.Certificate[0]) {
+		t.Error("Expected the first certificate")
+	}
+
+	second, _, _ := writeTestCert(t, dir, "server", nil)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	time.Sleep(time.Millisecond)
+
+	cert, _ := reloader.GetCertificate(nil)
+	if string(cert.Certificate[0]) != string(second.Certificate[0]) {
+		t.Error("Expected the rotated certificate to be served")
+	}
+}
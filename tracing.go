@@ -0,0 +1,411 @@
+package revel
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span is a timed operation of a trace, e.g. the handling of a request or the
+// rendering of a template.  Its fields follow OpenTelemetry, and the W3C Trace
+// Context for the IDs.
+type Span struct {
+	TraceID    string                 `json:"traceId"`                // 32 hex digits
+	SpanID     string                 `json:"spanId"`                 // 16 hex digits
+	ParentID   string                 `json:"parentSpanId,omitempty"` // The parent span, possibly remote
+	Name       string                 `json:"name"`                   // e.g. "GET /hotels/:id", "template Hotels/Show.html"
+	Kind       string                 `json:"kind"`                   // "server" or "internal"
+	StartTime  time.Time              `json:"startTime"`
+	EndTime    time.Time              `json:"endTime"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"` // e.g. {"http.route": "/hotels/:id"}
+	Error      string                 `json:"error,omitempty"`
+
+	sampled bool // Whether to export the span
+	mutex   sync.Mutex
+}
+
+// SpanExporter receives the spans of sampled traces as they end.
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// TraceExporter, if set, enables tracing in the TracingFilter.  It may be set
+// by the application on initialization, or to a WriterExporter on os.Stdout
+// with "trace.exporter = stdout".
+var TraceExporter SpanExporter
+
+type spanContextKey struct{}
+
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "trace.exporter", Description: `"stdout" to write the spans of TracingFilter as JSON lines, unless TraceExporter is set`},
+		ConfigOption{Name: "trace.sample", Default: "1", Description: "The ratio of new traces to export, between 0 and 1"},
+	)
+
+	OnAppStart(func() {
+		switch exporter := Config.StringDefault("trace.exporter", ""); exporter {
+		case "":
+		case "stdout":
+			if TraceExporter == nil {
+				TraceExporter = NewWriterExporter(os.Stdout)
+			}
+		default:
+			WARN.Println("Unknown trace.exporter:", exporter)
+		}
+	})
+}
+
+// SpanFromContext returns the current span of the context, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// StartSpan starts a child of the current span of the context, returning a
+// context holding the new span.  If the context has no span (e.g. tracing is
+// disabled), it returns the context unchanged and a nil span, whose methods
+// do nothing.  The span must be ended with End.
+//
+// Example:
+//
+//      ctx, span := revel.StartSpan(c.Request.Context(), "load bookings")
+//      defer span.End()
+//      bookings, err := loadBookings(ctx, user)
+//      span.SetError(err)
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		TraceID:   parent.TraceID,
+		SpanID:    newTraceID(8),
+		ParentID:  parent.SpanID,
+		Name:      name,
+		Kind:      "internal",
+		StartTime: time.Now(),
+		sampled:   parent.sampled,
+	}
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// StartTrace starts the root span of a new trace, e.g. for a background job,
+// sampled at the ratio given by trace.sample.  It returns the context
+// unchanged and a nil span if no TraceExporter is set.
+func StartTrace(ctx context.Context, name string) (context.Context, *Span) {
+	if TraceExporter == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		TraceID:   newTraceID(16),
+		SpanID:    newTraceID(8),
+		Name:      name,
+		Kind:      "internal",
+		StartTime: time.Now(),
+		sampled:   sampleTrace(),
+	}
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// SetAttribute sets an attribute of the span, e.g. "cache.key".
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{})
+	}
+	s.Attributes[key] = value
+}
+
+// SetError marks the span as failed, if err is not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.Error = err.Error()
+	s.mutex.Unlock()
+}
+
+// End ends the span, and exports it if its trace is sampled.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.EndTime = time.Now()
+	s.mutex.Unlock()
+	if exporter := TraceExporter; exporter != nil && s.sampled {
+		exporter.ExportSpan(s)
+	}
+}
+
+// TraceParent returns the W3C traceparent header propagating the span to the
+// services it calls.
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceID + "-" + s.SpanID + "-" + flags
+}
+
+var traceParentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// startServerSpan starts the span of a request, continuing the trace of its
+// traceparent header if valid, or else starting a trace sampled at the ratio
+// given by trace.sample (1 by default).
+func startServerSpan(r *http.Request) *Span {
+	span := &Span{
+		SpanID:    newTraceID(8),
+		Name:      r.Method,
+		Kind:      "server",
+		StartTime: time.Now(),
+	}
+	if m := traceParentPattern.FindStringSubmatch(r.Header.Get("traceparent")); m != nil &&
+		strings.Trim(m[1], "0") != "" && strings.Trim(m[2], "0") != "" {
+		flags, _ := strconv.ParseUint(m[3], 16, 8)
+		span.TraceID, span.ParentID, span.sampled = m[1], m[2], flags&1 == 1
+	} else {
+		span.TraceID, span.sampled = newTraceID(16), sampleTrace()
+	}
+	span.SetAttribute("http.request.method", r.Method)
+	span.SetAttribute("url.path", r.URL.Path)
+	return span
+}
+
+// sampleTrace returns whether to export a new trace, given trace.sample.
+func sampleTrace() bool {
+	if Config == nil {
+		return true
+	}
+	ratio, err := strconv.ParseFloat(Config.StringDefault("trace.sample", "1"), 64)
+	if err != nil || ratio >= 1 {
+		return true
+	}
+	return randomRatio() < ratio
+}
+
+// newTraceID returns a random ID of the given number of bytes, in hex.
+func newTraceID(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func randomRatio() float64 {
+	const precision = 1 << 53
+	n, err := rand.Int(rand.Reader, big.NewInt(precision))
+	if err != nil {
+		return 0
+	}
+	return float64(n.Int64()) / precision
+}
+
+// TracingFilter traces the requests, when a TraceExporter is set.  It starts a
+// server span for each request, named after its route, as a child of the
+// span given by the traceparent header if any.  Each of the following filter
+// stages, the action and the template rendering run in child spans.  The span
+// is held by the context of the request, so that the app may start spans of
+// its own with StartSpan, including for the cache (see cache.Traced).
+//
+// It is not one of the default Filters.  Add it first to trace every request:
+//
+//      revel.Filters = []revel.Filter{
+//          revel.TracingFilter,
+//          revel.PanicFilter,
+//          ...
+//      }
+func TracingFilter(c *Controller, fc []Filter) {
+	if TraceExporter == nil {
+		fc[0](c, fc[1:])
+		return
+	}
+
+	span := startServerSpan(c.Request.Request)
+	c.Request.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), spanContextKey{}, span))
+	traced := traceFilters(fc)
+	traced[0](c, traced[1:])
+
+	// Name the span after the route, now that the router has run.
+	if c.Action != "" && MainRouter != nil {
+		if route := MainRouter.match(c.Request.Method, c.Request.URL.Path); route != nil && route.Path != "" {
+			span.Name = c.Request.Method + " " + route.Path
+			span.SetAttribute("http.route", route.Path)
+		}
+		span.SetAttribute("revel.action", c.Action)
+	}
+	if c.Result == nil {
+		endServerSpan(c, span)
+		return
+	}
+	c.Result = &tracingResult{c.Result, c, span}
+}
+
+// tracingResult applies a result, then ends the server span of the request.
+type tracingResult struct {
+	Result
+	c    *Controller
+	span *Span
+}
+
+func (r *tracingResult) Apply(req *Request, resp *Response) {
+	r.Result.Apply(req, resp)
+	endServerSpan(r.c, r.span)
+}
+
+func endServerSpan(c *Controller, span *Span) {
+	status := c.Response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	span.SetAttribute("http.response.status_code", status)
+	if status >= 500 {
+		span.Error = http.StatusText(status)
+	}
+	span.End()
+}
+
+// traceFilters returns the filter stages, each running in a span of its own,
+// except for the last (the ActionInvoker, which traces the action).
+func traceFilters(fc []Filter) []Filter {
+	traced := make([]Filter, len(fc))
+	for i, f := range fc {
+		if i == len(fc)-1 {
+			traced[i] = f
+			break
+		}
+		traced[i] = traceFilter(f)
+	}
+	return traced
+}
+
+func traceFilter(f Filter) Filter {
+	name := "filter " + filterName(f)
+	return func(c *Controller, fc []Filter) {
+		restore := withSpan(c, name)
+		defer restore()
+		f(c, fc)
+	}
+}
+
+// withSpan starts a child of the current span of the request, and makes it the
+// current span until the returned function is called, which ends it.  It does
+// nothing if the request is not traced.
+func withSpan(c *Controller, name string) func() {
+	parent := c.Request.Context()
+	ctx, span := StartSpan(parent, name)
+	if span == nil {
+		return func() {}
+	}
+	c.Request.Request = c.Request.WithContext(ctx)
+	return func() {
+		// Copy the request again, as the filters may have changed it.
+		c.Request.Request = c.Request.WithContext(parent)
+		span.End()
+	}
+}
+
+var (
+	filterNames      = make(map[uintptr]string)
+	filterNamesMutex sync.Mutex
+)
+
+// filterName returns the name of a filter, e.g. "revel.RouterFilter".
+func filterName(f Filter) string {
+	pc := reflect.ValueOf(f).Pointer()
+	filterNamesMutex.Lock()
+	defer filterNamesMutex.Unlock()
+	if name, ok := filterNames[pc]; ok {
+		return name
+	}
+
+	// The filters declared as variables have no name of their own.
+	var name string
+	for varName, filter := range map[string]Filter{
+		"revel.ConfigPageFilter": ConfigPageFilter,
+		"revel.HealthFilter":     HealthFilter,
+		"revel.MetricsFilter":    MetricsFilter,
+		"revel.WatchFilter":      WatchFilter,
+	} {
+		if reflect.ValueOf(filter).Pointer() == pc {
+			name = varName
+		}
+	}
+	if name == "" {
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			name = fn.Name()
+			name = name[strings.LastIndex(name, "/")+1:]
+		}
+	}
+	filterNames[pc] = name
+	return name
+}
+
+// InMemoryExporter keeps the exported spans in memory, e.g. for tests.
+type InMemoryExporter struct {
+	mutex sync.Mutex
+	spans []*Span
+}
+
+func (e *InMemoryExporter) ExportSpan(span *Span) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the spans exported so far, in the order they ended.
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+// Reset forgets the spans exported so far.
+func (e *InMemoryExporter) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = nil
+}
+
+// WriterExporter writes each span as a line of JSON, e.g. to os.Stdout, to be
+// collected by an agent.
+type WriterExporter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+func (e *WriterExporter) ExportSpan(span *Span) {
+	span.mutex.Lock()
+	b, err := json.Marshal(span)
+	span.mutex.Unlock()
+	if err != nil {
+		ERROR.Println("Failed to export span", span.Name, ":", err)
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.w.Write(append(b, '\n'))
+}
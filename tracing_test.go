@@ -0,0 +1,89 @@
+package revel
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracingFilter(t *testing.T) {
+	startFakeBookingApp()
+	oldFilters, oldExporter := Filters, TraceExporter
+	defer func() { Filters, TraceExporter = oldFilters, oldExporter }()
+	exporter := &InMemoryExporter{}
+	TraceExporter = exporter
+	Filters = []Filter{TracingFilter, PanicFilter, RouterFilter, ParamsFilter, ActionInvoker}
+
+	req, _ := http.NewRequest("GET", "/hotels/3", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handle(httptest.NewRecorder(), req)
+
+	spans := exporter.Spans()
+	names := make(map[string]*Span)
+	for _, span := range spans {
+		names[span.Name] = span
+		if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Expected span %s to continue the trace, got %s", span.Name, span.TraceID)
+		}
+	}
+	server := names["GET /hotels/:id"]
+	if server == nil || len(spans) != 6 {
+
+		t.Fatalf("Expected 6 spans including GET /hotels/:id, got %v", names)
+	}
+	if server.ParentID != "00f067aa0ba902b7" || server.Kind != "server" ||
+		server.Attributes["http.route"] != "/hotels/:id" || server.Attributes["http.response.status_code"] != 200 {
+		t.Errorf("Unexpected server span: %+v", server)
+	}
+
+	// Each filter stage is a child of the previous one, and the template is
+	// rendered once the filters have run.
+	parents := map[string]string{
+		"filter revel.PanicFilter":  "GET /hotels/:id",
+		"filter revel.RouterFilter": "filter revel.PanicFilter",
+		"filter revel.ParamsFilter": "filter revel.RouterFilter",
+		"action Hotels.Show":        "filter revel.ParamsFilter",
+		"template hotels/show.html": "GET /hotels/:id",
+	}
+	for name, parent := range parents {
+		if span, ok := names[name]; !ok || names[parent] == nil || span.ParentID != names[parent].SpanID {
+			t.Errorf("Expected span %s to be a child of %s, got %v", name, parent, span)
+		}
+	}
+	if spans[len(spans)-1] != server {
+		t.Error("Expected the server span to end last")
+	}
+
+	// Without a traceparent, a new trace starts.
+	exporter.Reset()
+	handle(httptest.NewRecorder(), showRequest)
+	if spans = exporter.Spans(); len(spans) != 6 || spans[5].ParentID != "" || len(spans[5].TraceID) != 32 {
+		t.Errorf("Expected a new trace, got %v", spans)
+	}
+
+	// An unsampled trace is not exported.
+	exporter.Reset()
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	handle(httptest.NewRecorder(), req)
+	if spans = exporter.Spans(); len(spans) != 0 {
+		t.Errorf("Expected no span of an unsampled trace, got %d", len(spans))
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var b bytes.Buffer
+	span := &Span{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Name: "job"}
+	span.SetAttribute("jobs", 3)
+	NewWriterExporter(&b).ExportSpan(span)
+
+	var exported map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &exported); err != nil {
+		t.Fatal(err)
+	}
+	if exported["traceId"] != span.TraceID || exported["name"] != "job" ||
+		exported["attributes"].(map[string]interface{})["jobs"] != float64(3) {
+		t.Errorf("Unexpected exported span: %s", b.String())
+	}
+}
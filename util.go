@@ -83,15 +83,31 @@ var mimeConfig *MergedConfig
 
 // Load mime-types.conf on init.
 func LoadMimeConfig() {
+	if err := LoadMimeConfigE(); err != nil {
+		ERROR.Fatalln(err)
+	}
+}
+
+// LoadMimeConfigE loads mime-types.conf, returning an *Error on failure.
+func LoadMimeConfigE() error {
 	var err error
 	mimeConfig, err = LoadConfig("mime-types.conf")
 	if err != nil {
-		ERROR.Fatalln("Failed to load mime type config:", err)
+		return &Error{
+			Title:       "Failed to load mime type config",
+			Description: err.Error(),
+		}
 	}
+	return nil
 }
 
 func init() {
-	OnAppStart(LoadMimeConfig)
+	OnAppStart(func() {
+		// The panic is turned back into an error by initServer.
+		if err := LoadMimeConfigE(); err != nil {
+			panic(err)
+		}
+	})
 }
 
 // Returns a MIME content type based on the filename's extension.
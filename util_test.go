@@ -15,7 +15,7 @@ func TestContentTypeByFilename(t *testing.T) {
 		"helloworld":    "application/octet-stream",
 		"hello.world.c": "text/x-c; charset=utf-8",
 	}
-	srcPath, _ := findSrcPaths(REVEL_IMPORT_PATH)
+	srcPath, _, _ := findSrcPaths(REVEL_IMPORT_PATH)
 	ConfPaths = []string{path.Join(
 		srcPath,
 		filepath.FromSlash(REVEL_IMPORT_PATH),
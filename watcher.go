@@ -9,6 +9,12 @@ import (
 	"sync"
 )
 
+func init() {
+	RegisterConfigOptions(
+		ConfigOption{Name: "watcher.mode", Default: "normal", Description: `"eager" to rebuild as soon as a file changes`},
+	)
+}
+
 // Listener is an interface for receivers of filesystem events.
 type Listener interface {
 	// Refresh is invoked by the watcher on relevant filesystem events.